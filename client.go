@@ -0,0 +1,630 @@
+package sdk
+
+import (
+	"context"
+	"io"
+	"net"
+	"os/exec"
+	"sync"
+	"time"
+
+	plugin "github.com/hashicorp/go-plugin"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+// ProviderClient is a client of a provider plugin, exchanging cty values
+// with it instead of their wire encoding. Values of resources and data
+// sources are encoded using the schemas the provider returns, which the
+// client fetches once.
+type ProviderClient struct {
+	client pb.ProviderClient
+
+	// pluginClient is the client of the launched provider, conn the
+	// connection to the attached or in-process provider
+	pluginClient *plugin.Client
+	conn         *grpc.ClientConn
+
+	// server serves the in-process provider
+	server *grpc.Server
+
+	mu     sync.Mutex
+	schema *GetSchemaResponse
+}
+
+// NewProviderClient returns a client of the provider served on conn.
+func NewProviderClient(conn *grpc.ClientConn) *ProviderClient {
+	return &ProviderClient{
+		client: pb.NewProviderClient(conn),
+	}
+}
+
+// LaunchProvider starts the provider binary at path and connects to it.
+// Close kills the provider.
+func LaunchProvider(path string, args ...string) (*ProviderClient, error) {
+	plugins := map[string]plugin.Plugin{
+		"provider": &grpcPlugin{},
+	}
+	return dispenseProvider(plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         plugins,
+		VersionedPlugins: map[int]plugin.PluginSet{
+			DefaultProtocolVersion: plugins,
+		},
+		Cmd:              exec.Command(path, args...),
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+		Logger:           newLogger(false),
+	}))
+}
+
+// AttachProvider connects to a provider which is already running, such as
+// one served by ServeProviderDebug, at addr. Close disconnects from the
+// provider without stopping it.
+func AttachProvider(ctx context.Context, addr net.Addr) (*ProviderClient, error) {
+	conn, err := grpc.DialContext(ctx, addr.String(), grpc.WithInsecure(), grpc.WithBlock(), grpc.WithDialer(func(a string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout(addr.Network(), a, timeout)
+	}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to connect to provider: %s", addr)
+	}
+
+	c := NewProviderClient(conn)
+	c.conn = conn
+	return c, nil
+}
+
+// InProcessProvider serves p in-process over an in-memory connection and
+// connects to it, so a provider can be driven without building a binary.
+// Close stops serving the provider.
+func InProcessProvider(ctx context.Context, p Provider, opts ...ServeOpt) (*ProviderClient, error) {
+	conf, err := newServeConfig(nil, opts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(serverOptions(newLogger(false), nil)...)
+	pb.RegisterProviderServer(server, newGRPCProviderServer(p, conf))
+	go server.Serve(lis)
+
+	conn, err := grpc.DialContext(ctx, "bufconn", grpc.WithInsecure(), grpc.WithBlock(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		server.Stop()
+		return nil, errors.Wrap(err, "unable to connect to in-process provider")
+	}
+
+	c := NewProviderClient(conn)
+	c.conn = conn
+	c.server = server
+	return c, nil
+}
+
+func dispenseProvider(pluginClient *plugin.Client) (*ProviderClient, error) {
+	rpcClient, err := pluginClient.Client()
+	if err != nil {
+		pluginClient.Kill()
+		return nil, errors.Wrap(err, "unable to connect to provider")
+	}
+	raw, err := rpcClient.Dispense("provider")
+	if err != nil {
+		pluginClient.Kill()
+		return nil, errors.Wrap(err, "unable to dispense provider")
+	}
+
+	c := raw.(*ProviderClient)
+	c.pluginClient = pluginClient
+	return c, nil
+}
+
+// Close kills the provider if the client launched it, stops it if it is
+// in-process, or disconnects from it if the client attached to it. A client created by NewProviderClient
+// leaves closing the connection to the caller.
+func (c *ProviderClient) Close() {
+	if c.pluginClient != nil {
+		c.pluginClient.Kill()
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	if c.server != nil {
+		c.server.Stop()
+	}
+}
+
+// GetSchema returns the schemas of the provider.
+func (c *ProviderClient) GetSchema(ctx context.Context) (*GetSchemaResponse, Diagnostics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.schema != nil {
+		return c.schema, nil, nil
+	}
+
+	resp, err := c.client.GetSchema(ctx, &pb.GetProviderSchema_Request{})
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return nil, diags, nil
+	}
+
+	p, err := schemaFromPB(resp.Provider)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to convert provider schema")
+	}
+	resources, err := mapSchemaFromPB(resp.ResourceSchemas)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	dataSources, err := mapSchemaFromPB(resp.DataSourceSchemas)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	c.schema = &GetSchemaResponse{
+		Provider:          p,
+		DataSourceSchemas: dataSources,
+		ResourceSchemas:   resources,
+	}
+	return c.schema, diags, nil
+}
+
+// WriteSchemaJSON writes the schemas of the provider to w like
+// WriteSchemaJSON.
+func (c *ProviderClient) WriteSchemaJSON(ctx context.Context, w io.Writer, providerName string) error {
+	schema, diags, err := c.GetSchema(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return errors.Wrap(diags, "unable to get schema")
+	}
+
+	p, err := pbSchema(schema.Provider)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	resources, err := pbMapSchema(schema.ResourceSchemas)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	dataSources, err := pbMapSchema(schema.DataSourceSchemas)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return encodeSchemaJSON(w, providerName, &pb.GetProviderSchema_Response{
+		Provider:          p,
+		ResourceSchemas:   resources,
+		DataSourceSchemas: dataSources,
+	})
+}
+
+func (c *ProviderClient) providerType(ctx context.Context) (cty.Type, error) {
+	schema, diags, err := c.GetSchema(ctx)
+	if err != nil {
+		return cty.NilType, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return cty.NilType, errors.Wrap(diags, "unable to get schema")
+	}
+	return schema.Provider.Block.ImpliedType(), nil
+}
+
+func (c *ProviderClient) resourceType(ctx context.Context, typeName string) (cty.Type, error) {
+	schema, diags, err := c.GetSchema(ctx)
+	if err != nil {
+		return cty.NilType, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return cty.NilType, errors.Wrap(diags, "unable to get schema")
+	}
+	s, ok := schema.ResourceSchemas[typeName]
+	if !ok {
+		return cty.NilType, errors.Errorf("unknown resource type: %s", typeName)
+	}
+	return s.Block.ImpliedType(), nil
+}
+
+func (c *ProviderClient) dataSourceType(ctx context.Context, typeName string) (cty.Type, error) {
+	schema, diags, err := c.GetSchema(ctx)
+	if err != nil {
+		return cty.NilType, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return cty.NilType, errors.Wrap(diags, "unable to get schema")
+	}
+	s, ok := schema.DataSourceSchemas[typeName]
+	if !ok {
+		return cty.NilType, errors.Errorf("unknown data source type: %s", typeName)
+	}
+	return s.Block.ImpliedType(), nil
+}
+
+// encodeValue encodes val, which is null if it is cty.NilVal, as msgpack.
+func encodeValue(val cty.Value, ty cty.Type) (*pb.DynamicValue, error) {
+	if val == cty.NilVal {
+		val = cty.NullVal(ty)
+	}
+	v, err := NewDynamicValue(val, ty, false)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return pbDynamicValue(v), nil
+}
+
+// decodeValue decodes v, which is null if the provider sent no value.
+func decodeValue(v *pb.DynamicValue, ty cty.Type) (cty.Value, error) {
+	dv := dynamicValue(v)
+	if dv.IsEmpty() {
+		return cty.NullVal(ty), nil
+	}
+	val, err := dv.Unmarshal(ty)
+	if err != nil {
+		return cty.NilVal, errors.WithStack(err)
+	}
+	return val, nil
+}
+
+// PrepareProviderConfig validates the provider configuration, returning
+// the configuration with any defaults applied.
+func (c *ProviderClient) PrepareProviderConfig(ctx context.Context, config cty.Value) (cty.Value, Diagnostics, error) {
+	ty, err := c.providerType(ctx)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	pbConfig, err := encodeValue(config, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+
+	resp, err := c.client.PrepareProviderConfig(ctx, &pb.PrepareProviderConfig_Request{
+		Config: pbConfig,
+	})
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return cty.NilVal, diags, nil
+	}
+
+	prepared, err := decodeValue(resp.PreparedConfig, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	return prepared, diags, nil
+}
+
+// Configure configures the provider.
+func (c *ProviderClient) Configure(ctx context.Context, config cty.Value, terraformVersion string) (Diagnostics, error) {
+	ty, err := c.providerType(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbConfig, err := encodeValue(config, ty)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	resp, err := c.client.Configure(ctx, &pb.Configure_Request{
+		TerraformVersion: terraformVersion,
+		Config:           pbConfig,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	return diags, errors.WithStack(err)
+}
+
+// ValidateResourceTypeConfig validates the configuration of a resource.
+func (c *ProviderClient) ValidateResourceTypeConfig(ctx context.Context, typeName string, config cty.Value) (Diagnostics, error) {
+	ty, err := c.resourceType(ctx, typeName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbConfig, err := encodeValue(config, ty)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	resp, err := c.client.ValidateResourceTypeConfig(ctx, &pb.ValidateResourceTypeConfig_Request{
+		TypeName: typeName,
+		Config:   pbConfig,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	return diags, errors.WithStack(err)
+}
+
+// ValidateDataSourceConfig validates the configuration of a data source.
+func (c *ProviderClient) ValidateDataSourceConfig(ctx context.Context, typeName string, config cty.Value) (Diagnostics, error) {
+	ty, err := c.dataSourceType(ctx, typeName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbConfig, err := encodeValue(config, ty)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	resp, err := c.client.ValidateDataSourceConfig(ctx, &pb.ValidateDataSourceConfig_Request{
+		TypeName: typeName,
+		Config:   pbConfig,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	return diags, errors.WithStack(err)
+}
+
+// UpgradeResourceState upgrades rawState, the JSON state of a resource
+// written by the given schema version, to the current schema.
+func (c *ProviderClient) UpgradeResourceState(ctx context.Context, typeName string, version int, rawState []byte) (cty.Value, Diagnostics, error) {
+	ty, err := c.resourceType(ctx, typeName)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+
+	resp, err := c.client.UpgradeResourceState(ctx, &pb.UpgradeResourceState_Request{
+		TypeName: typeName,
+		Version:  int64(version),
+		RawState: &pb.RawState{
+			Json: rawState,
+		},
+	})
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return cty.NilVal, diags, nil
+	}
+
+	upgraded, err := decodeValue(resp.UpgradedState, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	return upgraded, diags, nil
+}
+
+// ReadResource refreshes the state of a resource, returning a null value
+// if it no longer exists.
+func (c *ProviderClient) ReadResource(ctx context.Context, typeName string, current cty.Value) (cty.Value, Diagnostics, error) {
+	ty, err := c.resourceType(ctx, typeName)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	pbCurrent, err := encodeValue(current, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+
+	resp, err := c.client.ReadResource(ctx, &pb.ReadResource_Request{
+		TypeName:     typeName,
+		CurrentState: pbCurrent,
+	})
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return cty.NilVal, diags, nil
+	}
+
+	newState, err := decodeValue(resp.NewState, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	return newState, diags, nil
+}
+
+// PlannedChange is the change the provider plans for a resource.
+type PlannedChange struct {
+	PlannedState cty.Value
+	// RequiresReplace are the paths of the attributes whose change forces
+	// the resource to be replaced.
+	RequiresReplace []cty.Path
+}
+
+// PlanResourceChange plans the change of a resource from prior to
+// proposed, either of which is null when the resource is created or
+// destroyed.
+func (c *ProviderClient) PlanResourceChange(ctx context.Context, typeName string, prior, proposed, config cty.Value) (*PlannedChange, Diagnostics, error) {
+	ty, err := c.resourceType(ctx, typeName)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	pbPrior, err := encodeValue(prior, ty)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	pbProposed, err := encodeValue(proposed, ty)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	pbConfig, err := encodeValue(config, ty)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	resp, err := c.client.PlanResourceChange(ctx, &pb.PlanResourceChange_Request{
+		TypeName:         typeName,
+		PriorState:       pbPrior,
+		ProposedNewState: pbProposed,
+		Config:           pbConfig,
+	})
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return nil, diags, nil
+	}
+
+	planned, err := decodeValue(resp.PlannedState, ty)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	requiresReplace, err := attributePathsFromPB(resp.RequiresReplace)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return &PlannedChange{
+		PlannedState:    planned,
+		RequiresReplace: requiresReplace,
+	}, diags, nil
+}
+
+// ApplyResourceChange applies the planned change of a resource, returning
+// its new state.
+func (c *ProviderClient) ApplyResourceChange(ctx context.Context, typeName string, prior, planned, config cty.Value) (cty.Value, Diagnostics, error) {
+	ty, err := c.resourceType(ctx, typeName)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	pbPrior, err := encodeValue(prior, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	pbPlanned, err := encodeValue(planned, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	pbConfig, err := encodeValue(config, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+
+	resp, err := c.client.ApplyResourceChange(ctx, &pb.ApplyResourceChange_Request{
+		TypeName:     typeName,
+		PriorState:   pbPrior,
+		PlannedState: pbPlanned,
+		Config:       pbConfig,
+	})
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+
+	// the new state is returned along with errors, as the change may have
+	// been partially applied
+	newState, err := decodeValue(resp.NewState, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	return newState, diags, nil
+}
+
+// ImportedResource is a resource returned by ImportResourceState.
+type ImportedResource struct {
+	TypeName string
+	State    cty.Value
+}
+
+// ImportResourceState imports the resource with the given ID.
+func (c *ProviderClient) ImportResourceState(ctx context.Context, typeName, id string) ([]ImportedResource, Diagnostics, error) {
+	resp, err := c.client.ImportResourceState(ctx, &pb.ImportResourceState_Request{
+		TypeName: typeName,
+		Id:       id,
+	})
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return nil, diags, nil
+	}
+
+	imported := make([]ImportedResource, len(resp.ImportedResources))
+	for i, r := range resp.ImportedResources {
+		ty, err := c.resourceType(ctx, r.TypeName)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		state, err := decodeValue(r.State, ty)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to decode imported resource: %s", r.TypeName)
+		}
+		imported[i] = ImportedResource{
+			TypeName: r.TypeName,
+			State:    state,
+		}
+	}
+	return imported, diags, nil
+}
+
+// ReadDataSource reads a data source, returning its state.
+func (c *ProviderClient) ReadDataSource(ctx context.Context, typeName string, config cty.Value) (cty.Value, Diagnostics, error) {
+	ty, err := c.dataSourceType(ctx, typeName)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	pbConfig, err := encodeValue(config, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+
+	resp, err := c.client.ReadDataSource(ctx, &pb.ReadDataSource_Request{
+		TypeName: typeName,
+		Config:   pbConfig,
+	})
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	diags, err := diagnosticsFromPB(resp.Diagnostics)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return cty.NilVal, diags, nil
+	}
+
+	state, err := decodeValue(resp.State, ty)
+	if err != nil {
+		return cty.NilVal, nil, errors.WithStack(err)
+	}
+	return state, diags, nil
+}
+
+// Stop asks the provider to cancel its in-flight operations.
+func (c *ProviderClient) Stop(ctx context.Context) error {
+	resp, err := c.client.Stop(ctx, &pb.Stop_Request{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
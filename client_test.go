@@ -0,0 +1,121 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testAttachProvider serves p in debug mode and attaches a client to it.
+func testAttachProvider(t *testing.T, ctx context.Context, p Provider) *ProviderClient {
+	r, w := io.Pipe()
+	go ServeProviderDebug(ctx, p, WithProviderName("test"), func(conf *serveConfig) {
+		conf.debugOutput = w
+	})
+
+	var reattach map[string]reattachConfig
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "TF_REATTACH_PROVIDERS=") {
+			continue
+		}
+		value := strings.Trim(strings.TrimPrefix(line, "TF_REATTACH_PROVIDERS="), "'")
+		err := json.Unmarshal([]byte(value), &reattach)
+		if err != nil {
+			t.Fatal(err)
+		}
+		break
+	}
+	go io.Copy(ioutil.Discard, r)
+
+	conf := reattach["registry.terraform.io/hashicorp/test"]
+	c, err := AttachProvider(ctx, testAddr{conf.Addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+type testAddr struct {
+	reattachAddr
+}
+
+func (a testAddr) Network() string { return a.reattachAddr.Network }
+func (a testAddr) String() string  { return a.reattachAddr.String }
+
+func TestProviderClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := testAttachProvider(t, ctx, newTestProvider())
+	defer c.Close()
+
+	schema, diags, err := c.GetSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags.IsError() {
+		t.Fatal(diags)
+	}
+	thing, ok := schema.ResourceSchemas["test_thing"]
+	if !ok {
+		t.Fatalf("expected test_thing schema, got %v", schema.ResourceSchemas)
+	}
+	if !thing.Block.ImpliedType().Equals(blockType(&testResource{})) {
+		t.Fatalf("expected the served schema, got %#v", thing.Block.ImpliedType())
+	}
+
+	diags, err = c.Configure(ctx, cty.ObjectVal(map[string]cty.Value{
+		"endpoint": cty.StringVal("http://localhost"),
+	}), "0.12.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags.IsError() {
+		t.Fatal(diags)
+	}
+
+	config := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.NullVal(cty.String),
+		"name": cty.StringVal("foo"),
+		"tags": cty.NullVal(cty.Map(cty.String)),
+	})
+	proposed := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("foo"),
+		"tags": cty.NullVal(cty.Map(cty.String)),
+	})
+	plan, diags, err := c.PlanResourceChange(ctx, "test_thing", cty.NilVal, proposed, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags.IsError() {
+		t.Fatal(diags)
+	}
+	if plan.PlannedState.GetAttr("id").IsKnown() {
+		t.Fatalf("expected unknown id in planned state, got %#v", plan.PlannedState)
+	}
+
+	state, diags, err := c.ApplyResourceChange(ctx, "test_thing", cty.NilVal, plan.PlannedState, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags.IsError() {
+		t.Fatal(diags)
+	}
+	if got := state.GetAttr("id"); !got.RawEquals(cty.StringVal("test-id")) {
+		t.Fatalf("expected id test-id, got %#v", got)
+	}
+
+	_, _, err = c.ReadResource(ctx, "test_nothing", cty.NilVal)
+	if err == nil || !strings.Contains(err.Error(), "unknown resource type: test_nothing") {
+		t.Fatalf("expected unknown resource type error, got %v", err)
+	}
+}
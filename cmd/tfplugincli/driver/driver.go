@@ -0,0 +1,418 @@
+// Package driver runs single resource operations against a provider
+// plugin, with configuration and state read from JSON files, so a
+// provider can be exercised without Terraform.
+//
+// The tfplugincli command launches provider binaries. To load a provider
+// in-process instead, for example under a debugger, call Run from a main
+// package of your own:
+//
+//	func main() {
+//		os.Exit(driver.Run(context.Background(), os.Args[1:], provider.New(), os.Stdout, os.Stderr))
+//	}
+package driver // import "github.com/hashicorp/terraform-plugin-sdk/cmd/tfplugincli/driver"
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+
+	sdk "github.com/hashicorp/terraform-plugin-sdk"
+)
+
+type command func(d *driver, ctx context.Context) (sdk.Diagnostics, error)
+
+var commands = map[string]command{
+	"schema":   (*driver).schema,
+	"validate": (*driver).validate,
+	"plan":     (*driver).plan,
+	"apply":    (*driver).apply,
+	"read":     (*driver).read,
+	"import":   (*driver).importState,
+	"destroy":  (*driver).destroy,
+}
+
+type driver struct {
+	client *sdk.ProviderClient
+
+	providerName       string
+	typeName           string
+	configPath         string
+	statePath          string
+	providerConfigPath string
+	id                 string
+	terraformVersion   string
+
+	stdout io.Writer
+}
+
+// Run parses args, which are the flags followed by a command, and runs the
+// command against p if it is not nil, otherwise against the provider binary
+// given by the -provider flag. The resulting state is written to stdout as
+// JSON, and diagnostics to stderr. It returns the exit code: 1 if the
+// command failed or returned errors, 2 if args are invalid.
+func Run(ctx context.Context, args []string, p sdk.Provider, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tfplugincli", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	providerPath := fs.String("provider", "", "path of the provider binary to launch; must be set unless the provider is loaded in-process")
+	d := &driver{stdout: stdout}
+	fs.StringVar(&d.typeName, "type", "", "resource type name; must be set for all commands but schema")
+	fs.StringVar(&d.configPath, "config", "", "JSON file of the resource configuration")
+	fs.StringVar(&d.statePath, "state", "", "JSON file of the resource state, as written by apply")
+	fs.StringVar(&d.providerConfigPath, "provider-config", "", "JSON file of the provider configuration; default empty")
+	fs.StringVar(&d.id, "id", "", "resource ID to import")
+	fs.StringVar(&d.terraformVersion, "tf-version", "0.12.0", "Terraform version sent to the provider")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage of tfplugincli:\n")
+		fmt.Fprintf(stderr, "\ttfplugincli [flags] schema|validate|plan|apply|read|import|destroy\n")
+		fmt.Fprintf(stderr, "Flags:\n")
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || (p == nil && *providerPath == "") {
+		fs.Usage()
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command: %s\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+	if d.typeName == "" && fs.Arg(0) != "schema" {
+		fmt.Fprintf(stderr, "-type must be set for %s\n", fs.Arg(0))
+		return 2
+	}
+
+	if p != nil {
+		d.providerName = "provider"
+		d.client, err = sdk.InProcessProvider(ctx, p)
+	} else {
+		d.providerName = strings.TrimPrefix(filepath.Base(*providerPath), "terraform-provider-")
+		d.client, err = sdk.LaunchProvider(*providerPath)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return 1
+	}
+	defer d.client.Close()
+
+	diags, err := cmd(d, ctx)
+	writeDiagnostics(stderr, diags)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return 1
+	}
+	if diags.IsError() {
+		return 1
+	}
+	return 0
+}
+
+func (d *driver) resourceSchema(ctx context.Context) (sdk.Schema, error) {
+	schema, diags, err := d.client.GetSchema(ctx)
+	if err != nil {
+		return sdk.Schema{}, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return sdk.Schema{}, errors.Wrap(diags, "unable to get schema")
+	}
+	s, ok := schema.ResourceSchemas[d.typeName]
+	if !ok {
+		return sdk.Schema{}, errors.Errorf("unknown resource type: %s", d.typeName)
+	}
+	return s, nil
+}
+
+// configure prepares and sends the provider configuration.
+func (d *driver) configure(ctx context.Context) (sdk.Diagnostics, error) {
+	schema, diags, err := d.client.GetSchema(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if diags.IsError() {
+		return diags, nil
+	}
+
+	data := []byte("{}")
+	if d.providerConfigPath != "" {
+		data, err = ioutil.ReadFile(d.providerConfigPath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	config, err := unmarshalValue(data, schema.Provider.Block.ImpliedType())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read provider configuration")
+	}
+
+	prepared, diags, err := d.client.PrepareProviderConfig(ctx, config)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+	configureDiags, err := d.client.Configure(ctx, prepared, d.terraformVersion)
+	return append(diags, configureDiags...), errors.WithStack(err)
+}
+
+func (d *driver) readConfig(ty cty.Type) (cty.Value, error) {
+	if d.configPath == "" {
+		return cty.NilVal, errors.New("-config must be set")
+	}
+	config, err := readValue(d.configPath, ty)
+	return config, errors.Wrap(err, "unable to read configuration")
+}
+
+// readState reads the state file, returning a null value if it is not
+// set and required is false.
+func (d *driver) readState(ty cty.Type, required bool) (cty.Value, error) {
+	if d.statePath == "" {
+		if required {
+			return cty.NilVal, errors.New("-state must be set")
+		}
+		return cty.NullVal(ty), nil
+	}
+	state, err := readValue(d.statePath, ty)
+	return state, errors.Wrap(err, "unable to read state")
+}
+
+func (d *driver) writeJSON(v interface{}) error {
+	enc := json.NewEncoder(d.stdout)
+	enc.SetIndent("", "  ")
+	return errors.WithStack(enc.Encode(v))
+}
+
+func (d *driver) schema(ctx context.Context) (sdk.Diagnostics, error) {
+	if d.typeName == "" {
+		return nil, d.client.WriteSchemaJSON(ctx, d.stdout, d.providerName)
+	}
+
+	var buf strings.Builder
+	err := d.client.WriteSchemaJSON(ctx, &buf, d.providerName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var doc struct {
+		ProviderSchemas map[string]struct {
+			ResourceSchemas map[string]json.RawMessage `json:"resource_schemas"`
+		} `json:"provider_schemas"`
+	}
+	err = json.Unmarshal([]byte(buf.String()), &doc)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s, ok := doc.ProviderSchemas[d.providerName].ResourceSchemas[d.typeName]
+	if !ok {
+		return nil, errors.Errorf("unknown resource type: %s", d.typeName)
+	}
+	return nil, d.writeJSON(s)
+}
+
+func (d *driver) validate(ctx context.Context) (sdk.Diagnostics, error) {
+	schema, err := d.resourceSchema(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	config, err := d.readConfig(schema.Block.ImpliedType())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.client.ValidateResourceTypeConfig(ctx, d.typeName, config)
+}
+
+// planChange validates config and plans the change of the resource from
+// prior to config.
+func (d *driver) planChange(ctx context.Context, schema sdk.Schema, prior, config cty.Value) (*sdk.PlannedChange, sdk.Diagnostics, error) {
+	diags, err := d.client.ValidateResourceTypeConfig(ctx, d.typeName, config)
+	if err != nil || diags.IsError() {
+		return nil, diags, errors.WithStack(err)
+	}
+
+	proposed := proposedNewState(schema.Block, prior, config)
+	plan, planDiags, err := d.client.PlanResourceChange(ctx, d.typeName, prior, proposed, config)
+	return plan, append(diags, planDiags...), errors.WithStack(err)
+}
+
+func (d *driver) plan(ctx context.Context) (sdk.Diagnostics, error) {
+	schema, err := d.resourceSchema(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ty := schema.Block.ImpliedType()
+	config, err := d.readConfig(ty)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	prior, err := d.readState(ty, false)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	diags, err := d.configure(ctx)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+	plan, planDiags, err := d.planChange(ctx, schema, prior, config)
+	diags = append(diags, planDiags...)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+
+	requiresReplace := make([]string, len(plan.RequiresReplace))
+	for i, p := range plan.RequiresReplace {
+		requiresReplace[i] = formatPath(p)
+	}
+	sort.Strings(requiresReplace)
+	return diags, d.writeJSON(map[string]interface{}{
+		"planned_state":    jsonValue(plan.PlannedState),
+		"requires_replace": requiresReplace,
+	})
+}
+
+func (d *driver) apply(ctx context.Context) (sdk.Diagnostics, error) {
+	schema, err := d.resourceSchema(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ty := schema.Block.ImpliedType()
+	config, err := d.readConfig(ty)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	prior, err := d.readState(ty, false)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	diags, err := d.configure(ctx)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+	plan, planDiags, err := d.planChange(ctx, schema, prior, config)
+	diags = append(diags, planDiags...)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+
+	if !prior.IsNull() && len(plan.RequiresReplace) > 0 {
+		// replace the resource by destroying it and creating it anew
+		destroyDiags, err := d.destroyState(ctx, prior)
+		diags = append(diags, destroyDiags...)
+		if err != nil || diags.IsError() {
+			return diags, errors.WithStack(err)
+		}
+
+		prior = cty.NullVal(ty)
+		plan, planDiags, err = d.planChange(ctx, schema, prior, config)
+		diags = append(diags, planDiags...)
+		if err != nil || diags.IsError() {
+			return diags, errors.WithStack(err)
+		}
+	}
+
+	state, applyDiags, err := d.client.ApplyResourceChange(ctx, d.typeName, prior, plan.PlannedState, config)
+	diags = append(diags, applyDiags...)
+	if err != nil {
+		return diags, errors.WithStack(err)
+	}
+	// the state is written even if the change failed, as it may have been
+	// partially applied
+	return diags, d.writeJSON(jsonValue(state))
+}
+
+func (d *driver) read(ctx context.Context) (sdk.Diagnostics, error) {
+	schema, err := d.resourceSchema(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	current, err := d.readState(schema.Block.ImpliedType(), true)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	diags, err := d.configure(ctx)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+	state, readDiags, err := d.client.ReadResource(ctx, d.typeName, current)
+	diags = append(diags, readDiags...)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+	return diags, d.writeJSON(jsonValue(state))
+}
+
+func (d *driver) importState(ctx context.Context) (sdk.Diagnostics, error) {
+	if d.id == "" {
+		return nil, errors.New("-id must be set")
+	}
+
+	diags, err := d.configure(ctx)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+	imported, importDiags, err := d.client.ImportResourceState(ctx, d.typeName, d.id)
+	diags = append(diags, importDiags...)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+
+	// imported resources are read, as Terraform does, to complete their
+	// state
+	var resources []interface{}
+	for _, r := range imported {
+		state, readDiags, err := d.client.ReadResource(ctx, r.TypeName, r.State)
+		diags = append(diags, readDiags...)
+		if err != nil || diags.IsError() {
+			return diags, errors.WithStack(err)
+		}
+		resources = append(resources, map[string]interface{}{
+			"type_name": r.TypeName,
+			"state":     jsonValue(state),
+		})
+	}
+	return diags, d.writeJSON(resources)
+}
+
+func (d *driver) destroy(ctx context.Context) (sdk.Diagnostics, error) {
+	schema, err := d.resourceSchema(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	prior, err := d.readState(schema.Block.ImpliedType(), true)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	diags, err := d.configure(ctx)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+	destroyDiags, err := d.destroyState(ctx, prior)
+	diags = append(diags, destroyDiags...)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+	return diags, d.writeJSON(nil)
+}
+
+// destroyState plans and applies the destruction of the resource.
+func (d *driver) destroyState(ctx context.Context, prior cty.Value) (sdk.Diagnostics, error) {
+	null := cty.NullVal(prior.Type())
+	plan, diags, err := d.client.PlanResourceChange(ctx, d.typeName, prior, null, null)
+	if err != nil || diags.IsError() {
+		return diags, errors.WithStack(err)
+	}
+	_, applyDiags, err := d.client.ApplyResourceChange(ctx, d.typeName, prior, plan.PlannedState, null)
+	return append(diags, applyDiags...), errors.WithStack(err)
+}
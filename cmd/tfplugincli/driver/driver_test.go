@@ -0,0 +1,164 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+
+	sdk "github.com/hashicorp/terraform-plugin-sdk"
+)
+
+type testProvider struct {
+	things map[string]string
+	nextID int
+}
+
+func (p *testProvider) Configure(context.Context, string) error  { return nil }
+func (p *testProvider) Schema() sdk.Schema                       { return sdk.Schema{} }
+func (p *testProvider) DataSourceSchemas() map[string]sdk.Schema { return map[string]sdk.Schema{} }
+func (p *testProvider) ResourceSchemas() map[string]sdk.Schema {
+	return map[string]sdk.Schema{"test_thing": (&testThing{}).Schema()}
+}
+func (p *testProvider) DataSourceFactory(string) sdk.DataSource { return nil }
+func (p *testProvider) ResourceFactory(string) sdk.Resource     { return &testThing{p: p} }
+func (p *testProvider) UnmarshalState(cty.Value) error          { return nil }
+func (p *testProvider) MarshalState() (cty.Value, error)        { return cty.EmptyObjectVal, nil }
+
+type testThing struct {
+	p *testProvider
+
+	ID   string
+	Name string
+}
+
+func (r *testThing) Schema() sdk.Schema {
+	return sdk.Schema{
+		Block: sdk.Block{
+			Attributes: []sdk.Attribute{
+				{Name: "id", Type: cty.String, Computed: true},
+				{Name: "name", Type: cty.String, Required: true, ForceNew: true},
+			},
+		},
+	}
+}
+
+func (r *testThing) Read(context.Context) error {
+	if _, ok := r.p.things[r.ID]; !ok {
+		return sdk.DoesNotExistError()
+	}
+	r.Name = r.p.things[r.ID]
+	return nil
+}
+
+func (r *testThing) Create(context.Context) error {
+	r.p.nextID++
+	r.ID = fmt.Sprintf("thing-%d", r.p.nextID)
+	r.p.things[r.ID] = r.Name
+	return nil
+}
+
+func (r *testThing) Import(ctx context.Context, id string) error {
+	if _, ok := r.p.things[id]; !ok {
+		return sdk.DoesNotExistError()
+	}
+	r.ID = id
+	return nil
+}
+
+func (r *testThing) Delete(context.Context) error {
+	delete(r.p.things, r.ID)
+	return nil
+}
+
+func (r *testThing) UnmarshalState(v cty.Value) error {
+	if s := v.GetAttr("id"); !s.IsNull() && s.IsKnown() {
+		r.ID = s.AsString()
+	}
+	if s := v.GetAttr("name"); !s.IsNull() && s.IsKnown() {
+		r.Name = s.AsString()
+	}
+	return nil
+}
+
+func (r *testThing) MarshalState() (cty.Value, error) {
+	return cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.StringVal(r.ID),
+		"name": cty.StringVal(r.Name),
+	}), nil
+}
+
+func testRun(t *testing.T, p sdk.Provider, args ...string) (int, []byte) {
+	var stdout, stderr bytes.Buffer
+	code := Run(context.Background(), args, p, &stdout, &stderr)
+	t.Logf("tfplugincli %v: %d\n%s", args, code, stderr.String())
+	return code, stdout.Bytes()
+}
+
+func testWriteFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+	return path
+}
+
+func TestRunLifecycle(t *testing.T) {
+	p := &testProvider{things: map[string]string{}}
+	config := testWriteFile(t, "config.json", []byte(`{"name": "foo"}`))
+
+	code, out := testRun(t, p, "-type", "test_thing", "-config", config, "plan")
+	require.Equal(t, 0, code)
+	assert.JSONEq(t, `{"planned_state": {"id": "(known after apply)", "name": "foo"}, "requires_replace": []}`, string(out))
+
+	code, out = testRun(t, p, "-type", "test_thing", "-config", config, "apply")
+	require.Equal(t, 0, code)
+	assert.JSONEq(t, `{"id": "thing-1", "name": "foo"}`, string(out))
+	state := testWriteFile(t, "state.json", out)
+
+	code, out = testRun(t, p, "-type", "test_thing", "-state", state, "read")
+	require.Equal(t, 0, code)
+	assert.JSONEq(t, `{"id": "thing-1", "name": "foo"}`, string(out))
+
+	code, out = testRun(t, p, "-type", "test_thing", "-id", "thing-1", "import")
+	require.Equal(t, 0, code)
+	assert.JSONEq(t, `[{"type_name": "test_thing", "state": {"id": "thing-1", "name": "foo"}}]`, string(out))
+
+	code, _ = testRun(t, p, "-type", "test_thing", "-id", "missing", "import")
+	assert.Equal(t, 1, code)
+
+	// changing the name replaces the thing
+	config = testWriteFile(t, "config.json", []byte(`{"name": "bar"}`))
+	code, out = testRun(t, p, "-type", "test_thing", "-config", config, "-state", state, "apply")
+	require.Equal(t, 0, code)
+	assert.JSONEq(t, `{"id": "thing-2", "name": "bar"}`, string(out))
+	assert.Equal(t, map[string]string{"thing-2": "bar"}, p.things)
+	state = testWriteFile(t, "state.json", out)
+
+	code, out = testRun(t, p, "-type", "test_thing", "-state", state, "destroy")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "null\n", string(out))
+	assert.Empty(t, p.things)
+}
+
+func TestRunSchema(t *testing.T) {
+	code, out := testRun(t, &testProvider{}, "-type", "test_thing", "schema")
+	require.Equal(t, 0, code)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(out, &schema))
+	assert.Contains(t, schema, "block")
+}
+
+func TestRunUsage(t *testing.T) {
+	code, _ := testRun(t, &testProvider{}, "plan")
+	assert.Equal(t, 2, code)
+
+	code, _ = testRun(t, nil, "-type", "test_thing", "plan")
+	assert.Equal(t, 2, code)
+}
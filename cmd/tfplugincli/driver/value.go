@@ -0,0 +1,154 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	sdk "github.com/hashicorp/terraform-plugin-sdk"
+)
+
+// unknownValue stands in for unknown values in the JSON output.
+const unknownValue = "(known after apply)"
+
+func readValue(path string, ty cty.Type) (cty.Value, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cty.NilVal, errors.WithStack(err)
+	}
+	return unmarshalValue(data, ty)
+}
+
+// unmarshalValue decodes data as a value of ty, where JSON null is a null
+// value and omitted attributes are null.
+func unmarshalValue(data []byte, ty cty.Type) (cty.Value, error) {
+	if strings.TrimSpace(string(data)) == "null" {
+		return cty.NullVal(ty), nil
+	}
+	val, err := ctyjson.Unmarshal(data, ty)
+	if err != nil {
+		return cty.NilVal, errors.WithStack(err)
+	}
+	return val, nil
+}
+
+// proposedNewState merges config with prior like Terraform does before
+// planning: computed attributes which are not configured keep their prior
+// value, or are unknown if the resource is being created.
+func proposedNewState(b sdk.Block, prior, config cty.Value) cty.Value {
+	if config.IsNull() || !config.IsKnown() {
+		return config
+	}
+
+	vals := config.AsValueMap()
+	for _, att := range b.Attributes {
+		if !att.Computed || !vals[att.Name].IsNull() {
+			continue
+		}
+		if prior.IsNull() {
+			vals[att.Name] = cty.UnknownVal(att.Type)
+		} else {
+			vals[att.Name] = prior.GetAttr(att.Name)
+		}
+	}
+	for _, nb := range b.BlockTypes {
+		if nb.Nesting != sdk.NestingSingle {
+			continue
+		}
+		nestedPrior := cty.NullVal(nb.ImpliedType())
+		if !prior.IsNull() {
+			nestedPrior = prior.GetAttr(nb.TypeName)
+		}
+		vals[nb.TypeName] = proposedNewState(nb.Block, nestedPrior, vals[nb.TypeName])
+	}
+	if len(vals) == 0 {
+		return config
+	}
+	return cty.ObjectVal(vals)
+}
+
+// jsonValue returns val in a form encoding/json marshals like cty's JSON
+// encoding, except that unknown values are represented by unknownValue.
+func jsonValue(val cty.Value) interface{} {
+	switch {
+	case val.IsNull():
+		return nil
+	case !val.IsKnown():
+		return unknownValue
+	}
+
+	ty := val.Type()
+	switch {
+	case ty == cty.String:
+		return val.AsString()
+	case ty == cty.Number:
+		return json.Number(val.AsBigFloat().Text('f', -1))
+	case ty == cty.Bool:
+		return val.True()
+	case ty.IsObjectType() || ty.IsMapType():
+		m := map[string]interface{}{}
+		for k, v := range val.AsValueMap() {
+			m[k] = jsonValue(v)
+		}
+		return m
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		l := []interface{}{}
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			l = append(l, jsonValue(v))
+		}
+		return l
+	}
+
+	// dynamic values are encoded with their type, like cty does
+	data, err := ctyjson.Marshal(val, cty.DynamicPseudoType)
+	if err != nil {
+		return unknownValue
+	}
+	return json.RawMessage(data)
+}
+
+// formatPath formats path like a Terraform reference, for example
+// tags["name"] or rule[0].port.
+func formatPath(path cty.Path) string {
+	var sb strings.Builder
+	for _, step := range path {
+		switch step := step.(type) {
+		case cty.GetAttrStep:
+			if sb.Len() > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(step.Name)
+		case cty.IndexStep:
+			if step.Key.Type() == cty.String {
+				fmt.Fprintf(&sb, "[%q]", step.Key.AsString())
+			} else {
+				fmt.Fprintf(&sb, "[%s]", step.Key.AsBigFloat().Text('f', -1))
+			}
+		}
+	}
+	return sb.String()
+}
+
+func writeDiagnostics(w io.Writer, diags sdk.Diagnostics) {
+	for _, d := range diags {
+		severity := "Error"
+		if d.Severity == sdk.SeverityWarning {
+			severity = "Warning"
+		}
+		fmt.Fprintf(w, "%s: %s\n", severity, d.Summary)
+		if len(d.Path) > 0 {
+			fmt.Fprintf(w, "\n  on %s\n", formatPath(d.Path))
+		}
+		if d.Detail != "" && d.Detail != d.Summary {
+			fmt.Fprintf(w, "\n%s\n", d.Detail)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,22 @@
+// Command tfplugincli runs a single resource operation against a provider
+// binary without Terraform, reading configuration and state from JSON
+// files and writing the resulting state as JSON:
+//
+//	tfplugincli -provider ./terraform-provider-tls -type tls_private_key -config key.json apply > state.json
+//	tfplugincli -provider ./terraform-provider-tls -type tls_private_key -state state.json destroy
+package main // import "github.com/hashicorp/terraform-plugin-sdk/cmd/tfplugincli"
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/hashicorp/terraform-plugin-sdk/cmd/tfplugincli/driver"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := driver.Run(ctx, os.Args[1:], nil, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
@@ -19,7 +19,7 @@ import (
 )
 
 var (
-	mode         = flag.String("gen", "", "plugin generation type; must be set")
+	mode         = flag.String("gen", "", "plugin generation type (provider, resource, datasource or provisioner); must be set")
 	typeName     = flag.String("type", "", "type name; must be set")
 	resourceName = flag.String("name", "", "resource / data source name; must be set for resources and data sources")
 	output       = flag.String("output", "", "output file name; default srcdir/<source file>.generated.go")
@@ -89,6 +89,8 @@ func main() {
 		err = g.generateResource()
 	case "datasource":
 		err = g.generateDataSource()
+	case "provisioner":
+		err = g.generateProvisioner()
 	default:
 		err = errors.Errorf("unexpected mode: %s", *mode)
 	}
@@ -0,0 +1,20 @@
+package main
+
+func (g *Generator) generateProvisioner() error {
+	err := g.writeSchema()
+	if err != nil {
+		return err
+	}
+
+	err = g.writeUnmarshalState()
+	if err != nil {
+		return err
+	}
+
+	err = g.writeMarshalState()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/dave/jennifer/jen"
+)
+
+func TestGenerateProvisioner(t *testing.T) {
+	obj := parseGoType(t, `type provisioner struct {
+	Command string            `+"`"+`tf:"command,required"`+"`"+`
+	Env     map[string]string `+"`"+`tf:"env,optional"`+"`"+`
+}`, "provisioner")
+	g := Generator{
+		File:     jen.NewFile("main"),
+		typeName: "provisioner",
+	}
+	g.typesNamed = obj.Type().(*types.Named)
+	g.typesStruct = g.typesNamed.Underlying().(*types.Struct)
+
+	err := g.generateProvisioner()
+	if err != nil {
+		t.Fatalf("error generating code: %+v", err)
+	}
+	src := g.format()
+
+	_, err = parser.ParseFile(token.NewFileSet(), "provisioner.generated.go", src, 0)
+	if err != nil {
+		t.Fatalf("invalid Go generated: %s\n%s", err, src)
+	}
+	for _, method := range []string{
+		"func (r *provisioner) Schema() ",
+		"func (r *provisioner) UnmarshalState(",
+		"func (r *provisioner) MarshalState() (cty.Value, error)",
+	} {
+		if !strings.Contains(string(src), method) {
+			t.Fatalf("expected %q in generated code:\n%s", method, src)
+		}
+	}
+	for _, att := range []string{`"command"`, `"env"`} {
+		if !strings.Contains(string(src), att) {
+			t.Fatalf("expected attribute %s in generated code:\n%s", att, src)
+		}
+	}
+}
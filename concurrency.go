@@ -0,0 +1,243 @@
+package sdk
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fifoSemaphore is a counting semaphore handing out slots in the order they
+// were asked for, so no waiter is starved by later ones.
+type fifoSemaphore struct {
+	mu      sync.Mutex
+	size    int
+	held    int
+	waiters list.List // of chan struct{}, closed when granted
+}
+
+// acquire waits for a slot, returning ctx.Err() without one if ctx is done
+// first.
+func (s *fifoSemaphore) acquire(ctx context.Context) error {
+	s.mu.Lock()
+	if s.held < s.size && s.waiters.Len() == 0 {
+		s.held++
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	select {
+	case <-ready:
+		// granted while being cancelled, pass the slot on
+		s.mu.Unlock()
+		s.release()
+	default:
+		s.waiters.Remove(elem)
+		s.mu.Unlock()
+	}
+	return ctx.Err()
+}
+
+// release hands the slot to the first waiter, or frees it if there is none.
+func (s *fifoSemaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if front := s.waiters.Front(); front != nil {
+		s.waiters.Remove(front)
+		close(front.Value.(chan struct{}))
+		return
+	}
+	s.held--
+}
+
+// KeyedMutex is a set of mutexes identified by key, for example to
+// serialize changes to the rules of the same firewall. Waiters for a key
+// get the lock in the order they asked for it. The zero value is ready to
+// use.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sem fifoSemaphore
+	// refs counts the holder and waiters, the lock is dropped at zero
+	refs int
+}
+
+// Lock locks key, returning the func unlocking it, or ctx.Err() if ctx is
+// done before the lock is acquired.
+func (m *KeyedMutex) Lock(ctx context.Context, key string) (func(), error) {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = map[string]*keyedLock{}
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{sem: fifoSemaphore{size: 1}}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	err := l.sem.acquire(ctx)
+	if err != nil {
+		m.unref(key, l)
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.sem.release()
+			m.unref(key, l)
+		})
+	}, nil
+}
+
+func (m *KeyedMutex) unref(key string, l *keyedLock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l.refs--
+	if l.refs == 0 {
+		delete(m.locks, key)
+	}
+}
+
+// ConcurrencyLimiter is implemented by providers limiting how many
+// ReadResource and ApplyResourceChange calls for a resource type run at
+// once, keyed by type name. Types without a positive limit are not limited.
+type ConcurrencyLimiter interface {
+	ConcurrencyLimits() map[string]int
+}
+
+// concurrencyLimits holds the semaphores of the limited resource types of
+// a provider, built once on first use.
+type concurrencyLimits struct {
+	once       sync.Once
+	semaphores map[string]*fifoSemaphore
+}
+
+// acquireResource waits for a slot for the resource type if the provider
+// limits it, returning the func releasing the slot.
+func (s *Server) acquireResource(ctx context.Context, typeName string) (func(), error) {
+	l := &s.concurrency
+	l.once.Do(func() {
+		limiter, ok := s.Provider.(ConcurrencyLimiter)
+		if !ok {
+			return
+		}
+		l.semaphores = map[string]*fifoSemaphore{}
+		for n, limit := range limiter.ConcurrencyLimits() {
+			if limit > 0 {
+				l.semaphores[n] = &fifoSemaphore{size: limit}
+			}
+		}
+	})
+
+	sem, ok := l.semaphores[typeName]
+	if !ok {
+		return func() {}, nil
+	}
+	err := sem.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sem.release, nil
+}
+
+// RateLimiter is a token bucket limiting the rate of calls, for example to
+// an API shared by all resources of a provider. Callers of Wait are served
+// in the order they called it.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	tokens   float64
+	last     time.Time
+}
+
+// NewRateLimiter returns a limiter allowing perSecond calls per second on
+// average and bursts of up to burst calls. A perSecond of zero or less does
+// not limit calls at all.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	var interval time.Duration
+	if perSecond > 0 {
+		interval = time.Duration(float64(time.Second) / perSecond)
+	}
+	return &RateLimiter{
+		interval: interval,
+		burst:    burst,
+		tokens:   float64(burst),
+	}
+}
+
+// Wait blocks until a call is allowed. It returns ctx.Err() if ctx is done
+// first, and an error straight away if the wait would outlast the deadline
+// of ctx. It uses the clock set with WithClock.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		// unlimited
+		return nil
+	}
+	clock := clockFromContext(ctx)
+
+	l.mu.Lock()
+	now := clock.Now()
+	l.refill(now)
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens * float64(l.interval))
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+		l.tokens++
+		l.mu.Unlock()
+		return errors.Errorf("rate limit wait of %s would exceed the deadline", wait)
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	select {
+	case <-clock.After(wait):
+		return nil
+	case <-ctx.Done():
+		// give back the token reserved above
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// refill adds the tokens accrued since the last call, up to the burst.
+func (l *RateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+	if now.After(l.last) {
+		l.last = now
+	}
+}
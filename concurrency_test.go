@@ -0,0 +1,251 @@
+package sdk
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testWaiters waits until n callers are queued on s.
+func testWaiters(t *testing.T, s *fifoSemaphore, n int) {
+	t.Helper()
+
+	for i := 0; i < 1000; i++ {
+		s.mu.Lock()
+		queued := s.waiters.Len()
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d waiters", n)
+}
+
+func TestKeyedMutexFairness(t *testing.T) {
+	ctx := context.Background()
+	var m KeyedMutex
+
+	unlock, err := m.Lock(ctx, "fw-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sem := &m.locks["fw-1"].sem
+
+	// other keys are not blocked
+	other, err := m.Lock(ctx, "fw-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other()
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			unlock, err := m.Lock(ctx, "fw-1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			unlock()
+		}(i)
+		// queue the waiters one after another
+		testWaiters(t, sem, i+1)
+	}
+
+	unlock()
+	wg.Wait()
+	for i, n := range order {
+		if i != n {
+			t.Fatalf("expected waiters to get the lock in order, got %v", order)
+		}
+	}
+	if len(m.locks) != 0 {
+		t.Fatalf("expected unused locks to be dropped, got %v", m.locks)
+	}
+}
+
+func TestKeyedMutexCancellation(t *testing.T) {
+	var m KeyedMutex
+
+	unlock, err := m.Lock(context.Background(), "fw-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sem := &m.locks["fw-1"].sem
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := m.Lock(ctx, "fw-1")
+		errCh <- err
+	}()
+	testWaiters(t, sem, 1)
+	cancel()
+	if err := <-errCh; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// the cancelled waiter must not hold on to the lock
+	unlock()
+	unlock, err = m.Lock(context.Background(), "fw-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	if len(m.locks) != 0 {
+		t.Fatalf("expected unused locks to be dropped, got %v", m.locks)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := newFakeClock()
+	ctx := WithClock(context.Background(), clock)
+	l := NewRateLimiter(10, 2)
+
+	for i := 0; i < 4; i++ {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the burst is free, then one call every 100ms
+	if len(clock.waits) != 2 || clock.waits[0] != 100*time.Millisecond || clock.waits[1] != 100*time.Millisecond {
+		t.Fatalf("unexpected waits %v", clock.waits)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	clock := newFakeClock()
+	ctx := WithClock(context.Background(), clock)
+	for _, perSecond := range []float64{0, -1} {
+		l := NewRateLimiter(perSecond, 1)
+		for i := 0; i < 10; i++ {
+			if err := l.Wait(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(clock.waits) != 0 {
+		t.Fatalf("expected no wait, got %v", clock.waits)
+	}
+}
+
+func TestRateLimiterDeadline(t *testing.T) {
+	clock := newFakeClock()
+	l := NewRateLimiter(1, 1)
+
+	ctx, cancel := context.WithDeadline(WithClock(context.Background(), clock), clock.Now().Add(500*time.Millisecond))
+	defer cancel()
+	if err := l.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err := l.Wait(ctx)
+	if err == nil || !strings.Contains(err.Error(), "would exceed the deadline") {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if len(clock.waits) != 0 {
+		t.Fatalf("expected no wait, got %v", clock.waits)
+	}
+}
+
+func TestRateLimiterCancellation(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- l.Wait(ctx)
+	}()
+	cancel()
+	if err := <-errCh; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// the reserved token is given back
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens < -0.01 {
+		t.Fatalf("expected the token to be returned, got %v tokens", tokens)
+	}
+}
+
+// testLimitedProvider limits test_thing to one operation at a time.
+type testLimitedProvider struct {
+	*testProvider
+}
+
+func (p *testLimitedProvider) ConcurrencyLimits() map[string]int {
+	return map[string]int{"test_thing": 1}
+}
+
+func TestServerConcurrencyLimit(t *testing.T) {
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	p := &testLimitedProvider{testProvider: newTestProvider()}
+	p.resources["test_thing"] = func() Resource {
+		return &testResource{create: func(ctx context.Context, r *testResource) error {
+			started <- r.Name
+			<-release
+			return nil
+		}}
+	}
+	s := &Server{Provider: p}
+	testConfigure(t, s)
+
+	apply := func(name string) {
+		planned := testThingVal("", name, nil)
+		resp, err := s.ApplyResourceChange(context.Background(), &ApplyResourceChangeRequest{
+			TypeName:     "test_thing",
+			PriorState:   testMsgpack(t, cty.NullVal(planned.Type())),
+			PlannedState: testMsgpack(t, planned),
+		})
+		if err != nil {
+			t.Error(err)
+		} else if resp.Diagnostics.IsError() {
+			t.Error(resp.Diagnostics)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		apply("a")
+	}()
+	if name := <-started; name != "a" {
+		t.Fatalf("expected a to start, got %s", name)
+	}
+	go func() {
+		defer wg.Done()
+		apply("b")
+	}()
+	testWaiters(t, s.concurrency.semaphores["test_thing"], 1)
+
+	select {
+	case name := <-started:
+		t.Fatalf("expected %s to wait for a", name)
+	default:
+	}
+
+	release <- struct{}{}
+	if name := <-started; name != "b" {
+		t.Fatalf("expected b to start, got %s", name)
+	}
+	release <- struct{}{}
+	wg.Wait()
+}
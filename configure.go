@@ -0,0 +1,89 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// configureGate tracks whether the provider has been configured, so RPCs
+// calling resource code, which may dereference clients set up by Configure,
+// wait for an in-progress Configure and fail cleanly without one.
+type configureGate struct {
+	mu      sync.Mutex
+	attempt *configureAttempt
+}
+
+// configureAttempt is a single Configure call. configured is set before
+// done is closed, so it may be read once done is closed.
+type configureAttempt struct {
+	done       chan struct{}
+	configured bool
+}
+
+// begin marks a Configure as in progress. The returned func must be called
+// with whether the provider was configured once Configure has returned.
+func (g *configureGate) begin() func(configured bool) {
+	a := &configureAttempt{done: make(chan struct{})}
+
+	g.mu.Lock()
+	g.attempt = a
+	g.mu.Unlock()
+
+	return func(configured bool) {
+		a.configured = configured
+		close(a.done)
+	}
+}
+
+// current returns the latest Configure, or nil if there has been none.
+func (g *configureGate) current() *configureAttempt {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.attempt
+}
+
+// wait waits for an in-progress Configure to return, returning an error
+// diagnostic for rpc if the provider is not configured or ctx is done
+// first.
+func (g *configureGate) wait(ctx context.Context, rpc string) Diagnostics {
+	a := g.current()
+	if a == nil {
+		return Diagnostics{
+			{
+				Severity: SeverityError,
+				Summary:  "Provider not configured",
+				Detail:   fmt.Sprintf("%s was called before the provider was configured. This is a bug in Terraform, please report it.", rpc),
+			},
+		}
+	}
+	return a.wait(ctx, rpc)
+}
+
+// wait waits for the Configure to return, returning an error diagnostic
+// for rpc if it failed or ctx is done first. A later Configure does not
+// change the outcome.
+func (a *configureAttempt) wait(ctx context.Context, rpc string) Diagnostics {
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return Diagnostics{
+			{
+				Severity: SeverityError,
+				Summary:  "Provider not configured",
+				Detail:   fmt.Sprintf("%s was cancelled while waiting for the provider to be configured: %v", rpc, ctx.Err()),
+			},
+		}
+	}
+
+	if !a.configured {
+		return Diagnostics{
+			{
+				Severity: SeverityError,
+				Summary:  "Provider not configured",
+				Detail:   fmt.Sprintf("%s requires a configured provider, but configuring the provider failed.", rpc),
+			},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testConfigureProvider configures with the configure func.
+type testConfigureProvider struct {
+	*testProvider
+	configure func(context.Context) error
+}
+
+func (p *testConfigureProvider) Configure(ctx context.Context, tfVersion string) error {
+	return p.configure(ctx)
+}
+
+func testConfigureRequest(t *testing.T) *ConfigureRequest {
+	return &ConfigureRequest{
+		Config: testMsgpack(t, cty.ObjectVal(map[string]cty.Value{"endpoint": cty.NullVal(cty.String)})),
+	}
+}
+
+func testReadResourceRequest(t *testing.T) *ReadResourceRequest {
+	return &ReadResourceRequest{
+		TypeName:     "test_thing",
+		CurrentState: testMsgpack(t, testThingVal("test-id", "a", nil)),
+	}
+}
+
+func TestServerReadResourceNotConfigured(t *testing.T) {
+	ctx := context.Background()
+	p := &testConfigureProvider{
+		testProvider: newTestProvider(),
+		configure: func(context.Context) error {
+			return errors.New("invalid credentials")
+		},
+	}
+	s := &Server{Provider: p}
+
+	resp, err := s.ReadResource(ctx, testReadResourceRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Diagnostics.IsError() || !strings.Contains(resp.Diagnostics[0].Detail, "before the provider was configured") {
+		t.Fatalf("expected not configured diagnostics, got %v", resp.Diagnostics)
+	}
+
+	configure, err := s.Configure(ctx, testConfigureRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !configure.Diagnostics.IsError() {
+		t.Fatal("expected configure to fail")
+	}
+
+	resp, err = s.ReadResource(ctx, testReadResourceRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Diagnostics.IsError() || !strings.Contains(resp.Diagnostics[0].Detail, "configuring the provider failed") {
+		t.Fatalf("expected configure failed diagnostics, got %v", resp.Diagnostics)
+	}
+}
+
+func TestServerReadResourceWaitsForConfigure(t *testing.T) {
+	ctx := context.Background()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	p := &testConfigureProvider{
+		testProvider: newTestProvider(),
+		configure: func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		},
+	}
+	s := &Server{Provider: p}
+
+	configured := make(chan error, 1)
+	go func() {
+		_, err := s.Configure(ctx, testConfigureRequest(t))
+		configured <- err
+	}()
+	<-started
+
+	read := make(chan *ReadResourceResponse, 1)
+	go func() {
+		resp, err := s.ReadResource(ctx, testReadResourceRequest(t))
+		if err != nil {
+			t.Error(err)
+		}
+		read <- resp
+	}()
+
+	select {
+	case <-read:
+		t.Fatal("expected ReadResource to wait for Configure")
+	default:
+	}
+
+	close(release)
+	if err := <-configured; err != nil {
+		t.Fatal(err)
+	}
+	resp := <-read
+	if resp == nil || resp.Diagnostics.IsError() {
+		t.Fatalf("expected ReadResource to succeed once configured, got %v", resp)
+	}
+}
+
+func TestServerReadResourceCancelledWaitingForConfigure(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	p := &testConfigureProvider{
+		testProvider: newTestProvider(),
+		configure: func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		},
+	}
+	s := &Server{Provider: p}
+
+	go s.Configure(context.Background(), testConfigureRequest(t))
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp, err := s.ReadResource(ctx, testReadResourceRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Diagnostics.IsError() || !strings.Contains(resp.Diagnostics[0].Detail, "cancelled while waiting") {
+		t.Fatalf("expected cancelled diagnostics, got %v", resp.Diagnostics)
+	}
+}
+
+func TestConfigureGateLaterConfigure(t *testing.T) {
+	ctx := context.Background()
+	var g configureGate
+
+	first := g.begin()
+	waiting := g.current()
+	second := g.begin()
+
+	// the first Configure succeeds after the second has started
+	first(true)
+	if diags := waiting.wait(ctx, "ReadResource"); diags.IsError() {
+		t.Fatalf("expected the first Configure to be waited for, got %v", diags)
+	}
+
+	second(false)
+	if diags := waiting.wait(ctx, "ReadResource"); diags.IsError() {
+		t.Fatalf("expected the second Configure not to change the first, got %v", diags)
+	}
+	if diags := g.wait(ctx, "ReadResource"); !diags.IsError() {
+		t.Fatal("expected later RPCs to see the second Configure fail")
+	}
+}
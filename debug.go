@@ -0,0 +1,125 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	plugin "github.com/hashicorp/go-plugin"
+	"github.com/pkg/errors"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+// reattachConfig is the TF_REATTACH_PROVIDERS entry of a provider.
+type reattachConfig struct {
+	Protocol        string
+	ProtocolVersion int
+	Pid             int
+	Test            bool
+	Addr            reattachAddr
+}
+
+type reattachAddr struct {
+	Network string
+	String  string
+}
+
+// ServeProviderDebug serves p in-process, so it can run under a debugger,
+// and prints the TF_REATTACH_PROVIDERS value which makes Terraform use it
+// instead of launching the provider itself. It serves until ctx is
+// cancelled or Terraform shuts the provider down.
+func ServeProviderDebug(ctx context.Context, p Provider, opts ...ServeOpt) error {
+	conf, err := newServeConfig(nil, opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return serveDebug(ctx, conf, newGRPCProviderServer(p, conf))
+}
+
+func serveDebug(ctx context.Context, conf *serveConfig, providerServer pb.ProviderServer) error {
+	lis, cleanup, err := debugListener()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer cleanup()
+
+	metrics := metricsFromEnv()
+	defer metrics.dump()
+	server := &plugin.GRPCServer{
+		Plugins: map[string]plugin.Plugin{
+			"provider": &grpcPlugin{
+				providerServer: providerServer,
+			},
+		},
+		Server: grpcServerFactory(newLogger(false), metrics),
+		DoneCh: make(chan struct{}),
+	}
+	err = server.Init()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	go server.Serve(lis)
+
+	reattach, err := json.Marshal(map[string]reattachConfig{
+		conf.providerAddress: {
+			Protocol:        string(plugin.ProtocolGRPC),
+			ProtocolVersion: DefaultProtocolVersion,
+			Pid:             os.Getpid(),
+			Test:            true,
+			Addr: reattachAddr{
+				Network: lis.Addr().Network(),
+				String:  lis.Addr().String(),
+			},
+		},
+	})
+	if err != nil {
+		server.Stop()
+		return errors.WithStack(err)
+	}
+	fmt.Fprintf(conf.debugOutput, "Provider started, to attach Terraform set the TF_REATTACH_PROVIDERS environment variable:\n\n\tTF_REATTACH_PROVIDERS='%s'\n\n", reattach)
+
+	select {
+	case <-ctx.Done():
+		server.Stop()
+		<-server.DoneCh
+	case <-server.DoneCh:
+		// Terraform shut the provider down
+	}
+	return nil
+}
+
+// debugListener listens on a unix socket in a temporary directory, or on a
+// local TCP port where unix sockets are not supported.
+func debugListener() (net.Listener, func(), error) {
+	if runtime.GOOS == "windows" {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		return lis, func() {}, errors.WithStack(err)
+	}
+
+	dir, err := ioutil.TempDir("", "terraform-provider")
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	lis, err := net.Listen("unix", filepath.Join(dir, "plugin.sock"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, errors.WithStack(err)
+	}
+	return lis, func() { os.RemoveAll(dir) }, nil
+}
+
+// providerAddress returns the default address of the provider, assuming the
+// hashicorp namespace of the public registry for plain names.
+func providerAddress(name string) string {
+	if strings.Contains(name, "/") {
+		return name
+	}
+	return "registry.terraform.io/hashicorp/" + name
+}
@@ -0,0 +1,109 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+// testServeProviderDebug serves the test provider in debug mode, returning
+// the TF_REATTACH_PROVIDERS value it printed and the channel of its result.
+func testServeProviderDebug(t *testing.T, ctx context.Context, opts ...ServeOpt) (map[string]reattachConfig, chan error) {
+	t.Helper()
+
+	r, w := io.Pipe()
+	errCh := make(chan error, 1)
+	opts = append(opts, func(conf *serveConfig) {
+		conf.debugOutput = w
+	})
+	go func() {
+		errCh <- ServeProviderDebug(ctx, newTestProvider(), opts...)
+	}()
+
+	var reattach map[string]reattachConfig
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "TF_REATTACH_PROVIDERS=") {
+			continue
+		}
+		value := strings.Trim(strings.TrimPrefix(line, "TF_REATTACH_PROVIDERS="), "'")
+		err := json.Unmarshal([]byte(value), &reattach)
+		if err != nil {
+			t.Fatal(err)
+		}
+		break
+	}
+	go io.Copy(ioutil.Discard, r)
+	return reattach, errCh
+}
+
+func TestServeProviderDebug(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reattach, errCh := testServeProviderDebug(t, ctx, WithProviderName("test"))
+	conf, ok := reattach["registry.terraform.io/hashicorp/test"]
+	if !ok {
+		t.Fatalf("expected reattach config for the provider, got %#v", reattach)
+	}
+	if conf.Protocol != "grpc" || conf.ProtocolVersion != 5 || !conf.Test {
+		t.Fatalf("unexpected reattach config %#v", conf)
+	}
+
+	conn, err := grpc.Dial(conf.Addr.String, grpc.WithInsecure(), grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+		return net.DialTimeout(conf.Addr.Network, addr, timeout)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	resp, err := pb.NewProviderClient(conn).GetSchema(ctx, &pb.GetProviderSchema_Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resp.ResourceSchemas["test_thing"]; !ok {
+		t.Fatalf("expected test_thing schema, got %v", resp.ResourceSchemas)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("provider did not stop")
+	}
+}
+
+func TestServeProviderDebugAddress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reattach, errCh := testServeProviderDebug(t, ctx, WithProviderName("test"), WithProviderAddress("registry.example.com/acme/test"))
+	if _, ok := reattach["registry.example.com/acme/test"]; !ok {
+		t.Fatalf("expected reattach config for the provider address, got %#v", reattach)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("provider did not stop")
+	}
+}
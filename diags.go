@@ -1,8 +1,11 @@
 package sdk // import "github.com/hashicorp/terraform-plugin-sdk"
 
 import (
+	"fmt"
+	"os"
 	"strings"
 
+	multierror "github.com/hashicorp/go-multierror"
 	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -14,12 +17,79 @@ const (
 	SeverityWarning = Severity(pb.Diagnostic_WARNING)
 )
 
-func errorOrDiagnostics(err error) (Diagnostics, error) {
-	diags, ok := err.(Diagnostics)
-	if ok {
-		return diags, nil
+// diagnoser is implemented by errors which can describe themselves as
+// diagnostics.
+type diagnoser interface {
+	Diagnostics() Diagnostics
+}
+
+// errorDiagnostics converts an error returned by provider code into
+// diagnostics. Diagnostics wrapped with errors.Wrap or %w are unwrapped and
+// aggregated errors are converted one by one. Any other error becomes a
+// single error diagnostic, with its stack trace as the detail when debug
+// logging is enabled.
+func errorDiagnostics(err error) Diagnostics {
+	if err == nil {
+		return nil
+	}
+	if diags, ok := findDiagnostics(err); ok {
+		return diags
+	}
+
+	d := Diagnostic{
+		Severity: SeverityError,
+		Summary:  err.Error(),
+	}
+	if debugLogging() {
+		d.Detail = fmt.Sprintf("%+v", err)
+	}
+	return Diagnostics{d}
+}
+
+// findDiagnostics walks the chain of wrapped errors looking for diagnostics.
+func findDiagnostics(err error) (Diagnostics, bool) {
+	for err != nil {
+		switch err := err.(type) {
+		case Diagnostics:
+			return err, true
+		case diagnoser:
+			return err.Diagnostics(), true
+		case *multierror.Error:
+			return joinedDiagnostics(err.Errors), true
+		case interface{ Unwrap() []error }:
+			return joinedDiagnostics(err.Unwrap()), true
+		}
+		err = unwrapError(err)
+	}
+	return nil, false
+}
+
+func joinedDiagnostics(errs []error) Diagnostics {
+	var diags Diagnostics
+	for _, err := range errs {
+		diags = append(diags, errorDiagnostics(err)...)
+	}
+	return diags
+}
+
+func unwrapError(err error) error {
+	switch err := err.(type) {
+	case interface{ Cause() error }:
+		return err.Cause()
+	case interface{ Unwrap() error }:
+		return err.Unwrap()
+	}
+	return nil
+}
+
+// debugLogging reports whether Terraform was run with debug logging, which
+// it enables for any TF_LOG value other than the quieter levels.
+func debugLogging() bool {
+	switch level := strings.ToUpper(os.Getenv("TF_LOG")); level {
+	case "", "INFO", "WARN", "ERROR":
+		return false
 	}
-	return nil, err
+	return true
 }
 
 type Diagnostics []Diagnostic
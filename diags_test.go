@@ -0,0 +1,54 @@
+package sdk
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+func TestErrorDiagnostics(t *testing.T) {
+	warning := Diagnostics{
+		Diagnostic{Severity: SeverityWarning, Summary: "deprecated"},
+	}
+
+	for i, c := range []struct {
+		err      error
+		expected []string
+	}{
+		{nil, nil},
+		{errors.New("boom"), []string{"boom"}},
+		{errors.Wrap(errors.New("boom"), "create failed"), []string{"create failed: boom"}},
+		{errors.Wrap(warning, "create failed"), []string{"deprecated"}},
+		{fmt.Errorf("create failed: %w", warning), []string{"deprecated"}},
+		{multierror.Append(errors.New("first"), errors.Wrap(warning, "second")), []string{"first", "deprecated"}},
+		{errors.Wrap(multierror.Append(errors.New("first"), errors.New("second")), "wrapped"), []string{"first", "second"}},
+		{PartialStateError(errors.New("half done")), []string{"half done"}},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			var actual []string
+			for _, d := range errorDiagnostics(c.err) {
+				actual = append(actual, d.Summary)
+			}
+			if fmt.Sprint(actual) != fmt.Sprint(c.expected) {
+				t.Fatalf("expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestErrorDiagnosticsDebugDetail(t *testing.T) {
+	err := errors.New("boom")
+
+	t.Setenv("TF_LOG", "")
+	if d := errorDiagnostics(err)[0]; d.Detail != "" {
+		t.Fatalf("expected no detail, got %q", d.Detail)
+	}
+
+	t.Setenv("TF_LOG", "DEBUG")
+	if d := errorDiagnostics(err)[0]; !strings.Contains(d.Detail, "TestErrorDiagnosticsDebugDetail") {
+		t.Fatalf("expected stack trace in detail, got %q", d.Detail)
+	}
+}
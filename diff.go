@@ -0,0 +1,189 @@
+package sdk
+
+import (
+	"sort"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// valueDiff is a single difference between two values.
+type valueDiff struct {
+	// Path is the precise path of the difference. Set elements are
+	// addressed by an IndexStep keyed with the element value itself.
+	Path cty.Path
+
+	// AddressablePath is Path truncated at the first set, as set elements
+	// cannot be addressed in the wire protocol.
+	AddressablePath cty.Path
+
+	From cty.Value
+	To   cty.Value
+}
+
+// diffValues returns the structural differences between from and to.
+// Objects and maps are compared key by key, lists and tuples index by
+// index, and sets by element value. Unknown values cannot be descended
+// into, so a known value changing to unknown (or vice versa) is reported
+// at the path of the unknown value.
+func diffValues(from, to cty.Value) []valueDiff {
+	d := &differ{setDepth: -1}
+	d.diff(cty.Path{}, from, to)
+	return d.diffs
+}
+
+type differ struct {
+	diffs []valueDiff
+
+	// setDepth is the length of the path to the outermost set currently
+	// being walked, or -1 if not inside a set.
+	setDepth int
+}
+
+func (d *differ) add(path cty.Path, from, to cty.Value) {
+	path = path.Copy()
+	addressable := path
+	if d.setDepth >= 0 {
+		addressable = path[:d.setDepth]
+	}
+	d.diffs = append(d.diffs, valueDiff{
+		Path:            path,
+		AddressablePath: addressable,
+		From:            from,
+		To:              to,
+	})
+}
+
+func (d *differ) diff(path cty.Path, from, to cty.Value) {
+	switch {
+	case from == cty.NilVal || to == cty.NilVal:
+		if from != to {
+			d.add(path, from, to)
+		}
+		return
+	case !from.IsKnown() || !to.IsKnown():
+		if from.IsKnown() || to.IsKnown() {
+			d.add(path, from, to)
+		}
+		return
+	case from.IsNull() || to.IsNull():
+		if !from.IsNull() || !to.IsNull() {
+			d.add(path, from, to)
+		}
+		return
+	}
+
+	fromType, toType := from.Type(), to.Type()
+	switch {
+	case fromType.IsObjectType() && toType.IsObjectType():
+		d.diffKeyed(path, from, to, func(key string) cty.PathStep {
+			return cty.GetAttrStep{Name: key}
+		})
+	case fromType.IsMapType() && toType.IsMapType():
+		d.diffKeyed(path, from, to, func(key string) cty.PathStep {
+			return cty.IndexStep{Key: cty.StringVal(key)}
+		})
+	case isListish(fromType) && isListish(toType):
+		d.diffIndexed(path, from, to)
+	case fromType.IsSetType() && toType.IsSetType():
+		d.diffSet(path, from, to)
+	default:
+		if !fromType.Equals(toType) {
+			d.add(path, from, to)
+			return
+		}
+		eq := from.Equals(to)
+		if !eq.IsKnown() || eq.False() {
+			d.add(path, from, to)
+		}
+	}
+}
+
+func (d *differ) diffKeyed(path cty.Path, from, to cty.Value, step func(string) cty.PathStep) {
+	fromMap, toMap := from.AsValueMap(), to.AsValueMap()
+
+	for _, key := range sortedKeys(fromMap, toMap) {
+		fromVal, fromOK := fromMap[key]
+		toVal, toOK := toMap[key]
+		switch {
+		case !fromOK:
+			fromVal = cty.NullVal(toVal.Type())
+		case !toOK:
+			toVal = cty.NullVal(fromVal.Type())
+		}
+		d.diff(append(path, step(key)), fromVal, toVal)
+	}
+}
+
+func (d *differ) diffIndexed(path cty.Path, from, to cty.Value) {
+	fromVals, toVals := from.AsValueSlice(), to.AsValueSlice()
+
+	n := len(fromVals)
+	if len(toVals) > n {
+		n = len(toVals)
+	}
+	for i := 0; i < n; i++ {
+		var fromVal, toVal cty.Value
+		switch {
+		case i >= len(fromVals):
+			toVal = toVals[i]
+			fromVal = cty.NullVal(toVal.Type())
+		case i >= len(toVals):
+			fromVal = fromVals[i]
+			toVal = cty.NullVal(fromVal.Type())
+		default:
+			fromVal, toVal = fromVals[i], toVals[i]
+		}
+		d.diff(append(path, cty.IndexStep{Key: cty.NumberIntVal(int64(i))}), fromVal, toVal)
+	}
+}
+
+func (d *differ) diffSet(path cty.Path, from, to cty.Value) {
+	if d.setDepth < 0 {
+		d.setDepth = len(path)
+		defer func() {
+			d.setDepth = -1
+		}()
+	}
+
+	fromVals, toVals := from.AsValueSlice(), to.AsValueSlice()
+	matched := make([]bool, len(toVals))
+
+	for _, fromVal := range fromVals {
+		found := false
+		for i, toVal := range toVals {
+			if !matched[i] && fromVal.RawEquals(toVal) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			d.add(append(path, cty.IndexStep{Key: fromVal}), fromVal, cty.NullVal(fromVal.Type()))
+		}
+	}
+
+	for i, toVal := range toVals {
+		if !matched[i] {
+			d.add(append(path, cty.IndexStep{Key: toVal}), cty.NullVal(toVal.Type()), toVal)
+		}
+	}
+}
+
+func isListish(ty cty.Type) bool {
+	return ty.IsListType() || ty.IsTupleType()
+}
+
+func sortedKeys(maps ...map[string]cty.Value) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,96 @@
+package sdk
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestDiffValues(t *testing.T) {
+	for i, c := range []struct {
+		expected            []cty.Path
+		expectedAddressable []cty.Path
+		from                cty.Value
+		to                  cty.Value
+	}{
+		{
+			nil, nil,
+			cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x")}),
+			cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x")}),
+		},
+		{
+			[]cty.Path{cty.GetAttrPath("a")},
+			[]cty.Path{cty.GetAttrPath("a")},
+			cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x"), "b": cty.True}),
+			cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("y"), "b": cty.True}),
+		},
+		{
+			[]cty.Path{cty.GetAttrPath("m").Index(cty.StringVal("k2")), cty.GetAttrPath("m").Index(cty.StringVal("k3"))},
+			[]cty.Path{cty.GetAttrPath("m").Index(cty.StringVal("k2")), cty.GetAttrPath("m").Index(cty.StringVal("k3"))},
+			cty.ObjectVal(map[string]cty.Value{"m": cty.MapVal(map[string]cty.Value{"k1": cty.StringVal("a"), "k2": cty.StringVal("b")})}),
+			cty.ObjectVal(map[string]cty.Value{"m": cty.MapVal(map[string]cty.Value{"k1": cty.StringVal("a"), "k2": cty.StringVal("c"), "k3": cty.StringVal("d")})}),
+		},
+		{
+			[]cty.Path{cty.GetAttrPath("l").Index(cty.NumberIntVal(1)), cty.GetAttrPath("l").Index(cty.NumberIntVal(2))},
+			[]cty.Path{cty.GetAttrPath("l").Index(cty.NumberIntVal(1)), cty.GetAttrPath("l").Index(cty.NumberIntVal(2))},
+			cty.ObjectVal(map[string]cty.Value{"l": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")})}),
+			cty.ObjectVal(map[string]cty.Value{"l": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("c"), cty.StringVal("d")})}),
+		},
+		{
+			[]cty.Path{cty.GetAttrPath("s").Index(cty.StringVal("b")), cty.GetAttrPath("s").Index(cty.StringVal("c"))},
+			[]cty.Path{cty.GetAttrPath("s"), cty.GetAttrPath("s")},
+			cty.ObjectVal(map[string]cty.Value{"s": cty.SetVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")})}),
+			cty.ObjectVal(map[string]cty.Value{"s": cty.SetVal([]cty.Value{cty.StringVal("c"), cty.StringVal("a")})}),
+		},
+		{
+			[]cty.Path{cty.GetAttrPath("a")},
+			[]cty.Path{cty.GetAttrPath("a")},
+			cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x")}),
+			cty.ObjectVal(map[string]cty.Value{"a": cty.UnknownVal(cty.String)}),
+		},
+		{
+			nil, nil,
+			cty.ObjectVal(map[string]cty.Value{"a": cty.UnknownVal(cty.String)}),
+			cty.ObjectVal(map[string]cty.Value{"a": cty.UnknownVal(cty.String)}),
+		},
+		{
+			// dynamic values whose object type changes
+			[]cty.Path{cty.GetAttrPath("d").GetAttr("data").GetAttr("k2")},
+			[]cty.Path{cty.GetAttrPath("d").GetAttr("data").GetAttr("k2")},
+			cty.ObjectVal(map[string]cty.Value{"d": cty.ObjectVal(map[string]cty.Value{
+				"data": cty.ObjectVal(map[string]cty.Value{"k1": cty.StringVal("a")}),
+			})}),
+			cty.ObjectVal(map[string]cty.Value{"d": cty.ObjectVal(map[string]cty.Value{
+				"data": cty.ObjectVal(map[string]cty.Value{"k1": cty.StringVal("a"), "k2": cty.StringVal("b")}),
+			})}),
+		},
+		{
+			[]cty.Path{cty.GetAttrPath("d")},
+			[]cty.Path{cty.GetAttrPath("d")},
+			cty.ObjectVal(map[string]cty.Value{"d": cty.StringVal("1")}),
+			cty.ObjectVal(map[string]cty.Value{"d": cty.NumberIntVal(1)}),
+		},
+		{
+			[]cty.Path{cty.Path{}},
+			[]cty.Path{cty.Path{}},
+			cty.NullVal(cty.Object(map[string]cty.Type{"a": cty.String})),
+			cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x")}),
+		},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			diffs := diffValues(c.from, c.to)
+			if len(diffs) != len(c.expected) {
+				t.Fatalf("expected %d diffs, got %d: %#v", len(c.expected), len(diffs), diffs)
+			}
+			for j, d := range diffs {
+				if !cty.NewPathSet(c.expected[j]).Has(d.Path) {
+					t.Fatalf("diff %d: expected path %#v, got %#v", j, c.expected[j], d.Path)
+				}
+				if !cty.NewPathSet(c.expectedAddressable[j]).Has(d.AddressablePath) {
+					t.Fatalf("diff %d: expected addressable path %#v, got %#v", j, c.expectedAddressable[j], d.AddressablePath)
+				}
+			}
+		})
+	}
+}
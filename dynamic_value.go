@@ -0,0 +1,82 @@
+package sdk
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"github.com/zclconf/go-cty/cty/msgpack"
+)
+
+// DynamicValue is a value as sent over the wire, encoded as either msgpack
+// or JSON.
+type DynamicValue struct {
+	MsgPack []byte
+	JSON    []byte
+}
+
+// IsEmpty reports whether v has no encoded value.
+func (v DynamicValue) IsEmpty() bool {
+	return len(v.MsgPack) == 0 && len(v.JSON) == 0
+}
+
+// Unmarshal decodes v as a value of type ty, preferring msgpack if both
+// encodings are present.
+func (v DynamicValue) Unmarshal(ty cty.Type) (cty.Value, error) {
+	switch {
+	case len(v.MsgPack) > 0:
+		val, err := msgpack.Unmarshal(v.MsgPack, ty)
+		if err != nil {
+			return cty.NilVal, errors.Wrap(err, "unable to unmarshal msgpack value")
+		}
+		return val, nil
+	case len(v.JSON) > 0:
+		val, err := ctyjson.Unmarshal(v.JSON, ty)
+		if err != nil {
+			return cty.NilVal, errors.Wrap(err, "unable to unmarshal JSON value")
+		}
+		return val, nil
+	}
+	return cty.NilVal, errors.New("value has neither a msgpack nor a JSON encoding")
+}
+
+// NewDynamicValue encodes val as msgpack, or as JSON if asJSON is set and
+// val is wholly known.
+func NewDynamicValue(val cty.Value, ty cty.Type, asJSON bool) (DynamicValue, error) {
+	if asJSON && val.IsWhollyKnown() {
+		data, err := ctyjson.Marshal(val, ty)
+		if err != nil {
+			return DynamicValue{}, errors.Wrap(err, "unable to marshal JSON value")
+		}
+		return DynamicValue{JSON: data}, nil
+	}
+
+	data, err := msgpack.Marshal(val, ty)
+	if err != nil {
+		return DynamicValue{}, errors.Wrap(err, "unable to marshal msgpack value")
+	}
+	return DynamicValue{MsgPack: data}, nil
+}
+
+func (s *Server) encode(val cty.Value, ty cty.Type) (DynamicValue, error) {
+	return NewDynamicValue(val, ty, s.EncodeJSON)
+}
+
+// encodeState marshals the state of target, carrying over the timeouts
+// block of prior, and encodes it as a value of ty, within a marshal span if
+// ctx is traced.
+func (s *Server) encodeState(ctx context.Context, target interface {
+	MarshalState() (cty.Value, error)
+}, ty cty.Type, prior cty.Value) (DynamicValue, error) {
+	_, span := startSpan(ctx, "marshal")
+	defer span.End()
+
+	state, err := target.MarshalState()
+	if err != nil {
+		return DynamicValue{}, errors.WithStack(err)
+	}
+	state = withTimeoutsBlock(target, state, prior)
+
+	return s.encode(state, ty)
+}
@@ -0,0 +1,59 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+func TestNewDynamicValueJSONUnknown(t *testing.T) {
+	val := cty.ObjectVal(map[string]cty.Value{"id": cty.UnknownVal(cty.String)})
+
+	dv, err := NewDynamicValue(val, val.Type(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dv.JSON) != 0 || len(dv.MsgPack) == 0 {
+		t.Fatalf("expected unknown value to fall back to msgpack, got %#v", dv)
+	}
+
+	actual, err := dv.Unmarshal(val.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.RawEquals(val) {
+		t.Fatalf("expected %#v, got %#v", val, actual)
+	}
+}
+
+func TestServerReadResourceJSON(t *testing.T) {
+	s := &Server{Provider: newTestProvider(), EncodeJSON: true}
+	testConfigure(t, s)
+
+	current := testThingVal("test-id", "a", map[string]cty.Value{"k1": cty.StringVal("v1")})
+	data, err := ctyjson.Marshal(current, current.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := s.ReadResource(context.Background(), &ReadResourceRequest{
+		TypeName:     "test_thing",
+		CurrentState: DynamicValue{JSON: data},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.NewState.JSON) == 0 {
+		t.Fatalf("expected JSON encoded state, got %#v", resp.NewState)
+	}
+
+	state, err := resp.NewState.Unmarshal(current.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.RawEquals(current) {
+		t.Fatalf("expected %#v, got %#v", current, state)
+	}
+}
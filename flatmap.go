@@ -0,0 +1,303 @@
+package sdk
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// flatmapUnknown is the value the legacy SDK stored for unknown values.
+const flatmapUnknown = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+// flatmapValue decodes a legacy flatmap state, as written by the
+// helper/schema SDK, into a value of the block's implied type. Keys not
+// described by the block are ignored.
+func flatmapValue(m map[string]string, b Block) (cty.Value, error) {
+	return flatmapBlock(m, "", b)
+}
+
+func flatmapBlock(m map[string]string, prefix string, b Block) (cty.Value, error) {
+	vals := map[string]cty.Value{}
+	for _, att := range b.Attributes {
+		val, err := flatmapAttribute(m, prefix+att.Name, att.ImpliedType())
+		if err != nil {
+			return cty.NilVal, errors.Wrapf(err, "unable to decode attribute %s", prefix+att.Name)
+		}
+		vals[att.Name] = val
+	}
+	for _, nb := range b.BlockTypes {
+		val, err := flatmapNestedBlock(m, prefix+nb.TypeName, nb)
+		if err != nil {
+			return cty.NilVal, errors.Wrapf(err, "unable to decode block %s", prefix+nb.TypeName)
+		}
+		vals[nb.TypeName] = val
+	}
+	return cty.ObjectVal(vals), nil
+}
+
+func flatmapNestedBlock(m map[string]string, prefix string, nb NestedBlock) (cty.Value, error) {
+	ty := nb.Block.ImpliedType()
+
+	switch nb.Nesting {
+	case NestingSingle:
+		// the legacy SDK stored single blocks as lists of at most one item
+		count, ok, err := flatmapCount(m, prefix+".#")
+		if err != nil {
+			return cty.NilVal, err
+		}
+		if !ok {
+			if !flatmapHasChildren(m, prefix) {
+				return cty.NullVal(ty), nil
+			}
+			return flatmapBlock(m, prefix+".", nb.Block)
+		}
+		if count < 1 {
+			return cty.NullVal(ty), nil
+		}
+		return flatmapBlock(m, prefix+".0.", nb.Block)
+	case NestingList:
+		count, _, err := flatmapCount(m, prefix+".#")
+		if err != nil {
+			return cty.NilVal, err
+		}
+		if count == 0 {
+			return cty.ListValEmpty(ty), nil
+		}
+		vals := make([]cty.Value, 0, count)
+		for i := 0; i < count; i++ {
+			val, err := flatmapBlock(m, prefix+"."+strconv.Itoa(i)+".", nb.Block)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals = append(vals, val)
+		}
+		return cty.ListVal(vals), nil
+	case NestingSet:
+		keys := flatmapChildKeys(m, prefix, "#")
+		if len(keys) == 0 {
+			return cty.SetValEmpty(ty), nil
+		}
+		vals := make([]cty.Value, 0, len(keys))
+		for _, k := range keys {
+			val, err := flatmapBlock(m, prefix+"."+k+".", nb.Block)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals = append(vals, val)
+		}
+		return cty.SetVal(vals), nil
+	case NestingMap:
+		keys := flatmapChildKeys(m, prefix, "%")
+		if len(keys) == 0 {
+			return cty.MapValEmpty(ty), nil
+		}
+		vals := map[string]cty.Value{}
+		for _, k := range keys {
+			val, err := flatmapBlock(m, prefix+"."+k+".", nb.Block)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals[k] = val
+		}
+		return cty.MapVal(vals), nil
+	}
+	return cty.NilVal, errors.Errorf("unexpected nesting mode %d", nb.Nesting)
+}
+
+func flatmapAttribute(m map[string]string, key string, ty cty.Type) (cty.Value, error) {
+	switch {
+	case ty.IsPrimitiveType() || ty == cty.DynamicPseudoType:
+		s, ok := m[key]
+		if !ok {
+			return cty.NullVal(ty), nil
+		}
+		return flatmapPrimitive(s, ty)
+	case ty.IsListType():
+		return flatmapList(m, key, ty)
+	case ty.IsSetType():
+		return flatmapSet(m, key, ty)
+	case ty.IsMapType():
+		return flatmapMap(m, key, ty)
+	case ty.IsObjectType():
+		if !flatmapHasChildren(m, key) {
+			return cty.NullVal(ty), nil
+		}
+		vals := map[string]cty.Value{}
+		for name, aty := range ty.AttributeTypes() {
+			val, err := flatmapAttribute(m, key+"."+name, aty)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals[name] = val
+		}
+		return cty.ObjectVal(vals), nil
+	}
+	return cty.NilVal, errors.Errorf("unsupported type %s", ty.FriendlyName())
+}
+
+func flatmapPrimitive(s string, ty cty.Type) (cty.Value, error) {
+	if s == flatmapUnknown {
+		return cty.UnknownVal(ty), nil
+	}
+	switch ty {
+	case cty.String, cty.DynamicPseudoType:
+		return cty.StringVal(s), nil
+	case cty.Number:
+		return cty.ParseNumberVal(s)
+	case cty.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return cty.NilVal, errors.WithStack(err)
+		}
+		return cty.BoolVal(b), nil
+	}
+	return cty.NilVal, errors.Errorf("unsupported type %s", ty.FriendlyName())
+}
+
+func flatmapList(m map[string]string, key string, ty cty.Type) (cty.Value, error) {
+	if m[key+".#"] == flatmapUnknown {
+		return cty.UnknownVal(ty), nil
+	}
+	count, ok, err := flatmapCount(m, key+".#")
+	if err != nil || !ok {
+		return cty.NullVal(ty), err
+	}
+	ety := ty.ElementType()
+	if count == 0 {
+		return cty.ListValEmpty(ety), nil
+	}
+	vals := make([]cty.Value, 0, count)
+	for i := 0; i < count; i++ {
+		val, err := flatmapAttribute(m, key+"."+strconv.Itoa(i), ety)
+		if err != nil {
+			return cty.NilVal, err
+		}
+		vals = append(vals, val)
+	}
+	return cty.ListVal(vals), nil
+}
+
+func flatmapSet(m map[string]string, key string, ty cty.Type) (cty.Value, error) {
+	if m[key+".#"] == flatmapUnknown {
+		return cty.UnknownVal(ty), nil
+	}
+	_, ok, err := flatmapCount(m, key+".#")
+	if err != nil || !ok {
+		return cty.NullVal(ty), err
+	}
+	ety := ty.ElementType()
+	// set elements are keyed by their hash rather than an index
+	keys := flatmapChildKeys(m, key, "#")
+	if len(keys) == 0 {
+		return cty.SetValEmpty(ety), nil
+	}
+	vals := make([]cty.Value, 0, len(keys))
+	for _, k := range keys {
+		val, err := flatmapAttribute(m, key+"."+k, ety)
+		if err != nil {
+			return cty.NilVal, err
+		}
+		vals = append(vals, val)
+	}
+	return cty.SetVal(vals), nil
+}
+
+func flatmapMap(m map[string]string, key string, ty cty.Type) (cty.Value, error) {
+	countKey := key + ".%"
+	if _, ok := m[countKey]; !ok {
+		// very old states counted maps with # too
+		countKey = key + ".#"
+	}
+	if m[countKey] == flatmapUnknown {
+		return cty.UnknownVal(ty), nil
+	}
+	_, ok, err := flatmapCount(m, countKey)
+	if err != nil || !ok {
+		return cty.NullVal(ty), err
+	}
+	ety := ty.ElementType()
+
+	vals := map[string]cty.Value{}
+	if ety.IsPrimitiveType() {
+		// primitive map keys may themselves contain dots
+		prefix := key + "."
+		for k, s := range m {
+			if !strings.HasPrefix(k, prefix) || k == countKey {
+				continue
+			}
+			val, err := flatmapPrimitive(s, ety)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals[strings.TrimPrefix(k, prefix)] = val
+		}
+	} else {
+		for _, k := range flatmapChildKeys(m, key, "%", "#") {
+			val, err := flatmapAttribute(m, key+"."+k, ety)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals[k] = val
+		}
+	}
+	if len(vals) == 0 {
+		return cty.MapValEmpty(ety), nil
+	}
+	return cty.MapVal(vals), nil
+}
+
+// flatmapCount parses the count stored at key, reporting whether it is
+// present. Unknown counts are treated as zero.
+func flatmapCount(m map[string]string, key string) (int, bool, error) {
+	s, ok := m[key]
+	if !ok {
+		return 0, false, nil
+	}
+	if s == flatmapUnknown {
+		return 0, true, nil
+	}
+	count, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, true, errors.Wrapf(err, "invalid count for %s", key)
+	}
+	return count, true, nil
+}
+
+func flatmapHasChildren(m map[string]string, key string) bool {
+	prefix := key + "."
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// flatmapChildKeys returns the sorted distinct first key segments below
+// key, excluding the count keys.
+func flatmapChildKeys(m map[string]string, key string, counts ...string) []string {
+	prefix := key + "."
+	seen := map[string]bool{}
+	for k := range m {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		child := strings.TrimPrefix(k, prefix)
+		if i := strings.IndexByte(child, '.'); i >= 0 {
+			child = child[:i]
+		}
+		if stringInSlice(child, counts) {
+			continue
+		}
+		seen[child] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
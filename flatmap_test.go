@@ -0,0 +1,203 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestFlatmapValue(t *testing.T) {
+	block := Block{
+		Attributes: []Attribute{
+			{Name: "id", Type: cty.String, Computed: true},
+			{Name: "count", Type: cty.Number, Optional: true},
+			{Name: "enabled", Type: cty.Bool, Optional: true},
+			{Name: "names", Type: cty.List(cty.String), Optional: true},
+			{Name: "ports", Type: cty.Set(cty.Number), Optional: true},
+			{Name: "tags", Type: cty.Map(cty.String), Optional: true},
+			{Name: "missing", Type: cty.String, Optional: true},
+			{Name: "arn", Type: cty.String, Computed: true},
+		},
+		BlockTypes: []NestedBlock{
+			{
+				TypeName: "rule",
+				Nesting:  NestingList,
+				Block: Block{
+					Attributes: []Attribute{
+						{Name: "action", Type: cty.String, Required: true},
+					},
+				},
+			},
+			{
+				TypeName: "settings",
+				Nesting:  NestingSingle,
+				Block: Block{
+					Attributes: []Attribute{
+						{Name: "mode", Type: cty.String, Optional: true},
+					},
+				},
+			},
+			{
+				TypeName: "ingress",
+				Nesting:  NestingSet,
+				Block: Block{
+					Attributes: []Attribute{
+						{Name: "port", Type: cty.Number, Required: true},
+					},
+				},
+			},
+		},
+	}
+
+	m := map[string]string{
+		"id":                   "test-id",
+		"count":                "3",
+		"enabled":              "true",
+		"names.#":              "2",
+		"names.0":              "a",
+		"names.1":              "b",
+		"ports.#":              "2",
+		"ports.1234":           "80",
+		"ports.5678":           "443",
+		"tags.%":               "2",
+		"tags.env":             "prod",
+		"tags.example.com/app": "web",
+		"arn":                  flatmapUnknown,
+		"rule.#":               "1",
+		"rule.0.action":        "allow",
+		"settings.#":           "1",
+		"settings.0.mode":      "fast",
+		"ingress.#":            "0",
+		"legacy_removed":       "ignored",
+	}
+
+	actual, err := flatmapValue(m, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ruleType := cty.Object(map[string]cty.Type{"port": cty.Number})
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"id":      cty.StringVal("test-id"),
+		"count":   cty.NumberIntVal(3),
+		"enabled": cty.True,
+		"names":   cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		"ports":   cty.SetVal([]cty.Value{cty.MustParseNumberVal("80"), cty.MustParseNumberVal("443")}),
+		"tags": cty.MapVal(map[string]cty.Value{
+			"env":             cty.StringVal("prod"),
+			"example.com/app": cty.StringVal("web"),
+		}),
+		"missing": cty.NullVal(cty.String),
+		"arn":     cty.UnknownVal(cty.String),
+		"rule": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"action": cty.StringVal("allow")}),
+		}),
+		"settings": cty.ObjectVal(map[string]cty.Value{"mode": cty.StringVal("fast")}),
+		"ingress":  cty.SetValEmpty(ruleType),
+	})
+	if !actual.RawEquals(expected) {
+		t.Fatalf("expected %#v, got %#v", expected, actual)
+	}
+}
+
+func TestServerUpgradeResourceStateFlatmap(t *testing.T) {
+	s := &Server{Provider: newTestProvider()}
+
+	resp, err := s.UpgradeResourceState(context.Background(), &UpgradeResourceStateRequest{
+		TypeName: "test_thing",
+		RawStateFlatmap: map[string]string{
+			"id":      "test-id",
+			"name":    "a",
+			"tags.%":  "1",
+			"tags.k1": "v1",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := testThingVal("test-id", "a", map[string]cty.Value{"k1": cty.StringVal("v1")})
+	state, err := resp.UpgradedState.Unmarshal(expected.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.RawEquals(expected) {
+		t.Fatalf("expected %#v, got %#v", expected, state)
+	}
+}
+
+// testVersionedResource is a testResource at schema version 1.
+type testVersionedResource struct {
+	testResource
+}
+
+func (r *testVersionedResource) Schema() Schema {
+	schema := r.testResource.Schema()
+	schema.Version = 1
+	return schema
+}
+
+// testUpgradedResource upgrades the state of version 0, where name was
+// called title.
+type testUpgradedResource struct {
+	testVersionedResource
+}
+
+func (r *testUpgradedResource) UpgradeState(ctx context.Context, version int, rawState []byte) (cty.Value, error) {
+	var old struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+	err := json.Unmarshal(rawState, &old)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return testThingVal(old.ID, old.Title, nil), nil
+}
+
+func TestServerUpgradeResourceStateVersion(t *testing.T) {
+	p := newTestProvider()
+	p.resources["test_versioned"] = func() Resource { return &testVersionedResource{} }
+	p.resources["test_upgraded"] = func() Resource { return &testUpgradedResource{} }
+	s := &Server{Provider: p}
+	ctx := context.Background()
+
+	resp, err := s.UpgradeResourceState(ctx, &UpgradeResourceStateRequest{
+		TypeName:     "test_upgraded",
+		Version:      0,
+		RawStateJSON: []byte(`{"id": "test-id", "title": "a"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics %v", resp.Diagnostics)
+	}
+	expected := testThingVal("test-id", "a", nil)
+	state, err := resp.UpgradedState.Unmarshal(expected.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.RawEquals(expected) {
+		t.Fatalf("expected %#v, got %#v", expected, state)
+	}
+
+	for _, req := range []*UpgradeResourceStateRequest{
+		// newer than the schema
+		{TypeName: "test_upgraded", Version: 2, RawStateJSON: []byte(`{"id": "test-id", "name": "a"}`)},
+		// older, without an upgrader
+		{TypeName: "test_versioned", Version: 0, RawStateJSON: []byte(`{"id": "test-id", "name": "a"}`)},
+		// not matching the schema
+		{TypeName: "test_thing", RawStateJSON: []byte(`{"id": "test-id", "title": "a"}`)},
+	} {
+		resp, err := s.UpgradeResourceState(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.Diagnostics.IsError() {
+			t.Fatalf("expected error diagnostics for %s version %d", req.TypeName, req.Version)
+		}
+	}
+}
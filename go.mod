@@ -1,5 +1,7 @@
 module github.com/hashicorp/terraform-plugin-sdk
 
+go 1.16
+
 require (
 	github.com/dave/jennifer v1.3.0
 	github.com/go-test/deep v1.0.1
@@ -8,9 +10,10 @@ require (
 	github.com/hashicorp/errwrap v1.0.0
 	github.com/hashicorp/go-cleanhttp v0.5.0
 	github.com/hashicorp/go-getter v1.2.0
-	github.com/hashicorp/go-hclog v0.8.0 // indirect
+	github.com/hashicorp/go-hclog v0.8.0
 	github.com/hashicorp/go-multierror v1.0.0
 	github.com/hashicorp/go-plugin v1.0.1-0.20190430211030-5692942914bb
+	github.com/hashicorp/go-uuid v1.0.1
 	github.com/hashicorp/go-version v1.1.0
 	github.com/hashicorp/golang-lru v0.5.1 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
@@ -28,7 +31,7 @@ require (
 	github.com/ulikunitz/xz v0.5.6 // indirect
 	github.com/vmihailenco/msgpack v4.0.4+incompatible // indirect
 	github.com/zclconf/go-cty v0.0.0-20190430221426-d36a6f0dbffd
-	go.opencensus.io v0.19.1 // indirect
+	go.opencensus.io v0.19.1
 	golang.org/x/oauth2 v0.0.0-20190226205417-e64efc72b421 // indirect
 	golang.org/x/tools v0.0.0-20190501045030-23463209683d
 	google.golang.org/appengine v1.5.0 // indirect
@@ -2,12 +2,21 @@ package sdk // import "github.com/hashicorp/terraform-plugin-sdk"
 
 import (
 	"context"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
 
+	hclog "github.com/hashicorp/go-hclog"
 	plugin "github.com/hashicorp/go-plugin"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 
 	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+	pb6 "github.com/hashicorp/terraform-plugin-sdk/tfplugin6"
 )
 
 const (
@@ -28,34 +37,188 @@ var Handshake = plugin.HandshakeConfig{
 	MagicCookieValue: "d602bf8f470bc67ca7faa0386276bbdd4330efaf76d1a219cb4d6991ca9872b2",
 }
 
-func grpcServerFactory(opts []grpc.ServerOption) *grpc.Server {
-	allOpts := append(opts, grpc.UnaryInterceptor(LoggingServerInterceptor()))
-	return grpc.NewServer(allOpts...)
+func grpcServerFactory(logger hclog.Logger, metrics *Metrics) func([]grpc.ServerOption) *grpc.Server {
+	return func(opts []grpc.ServerOption) *grpc.Server {
+		allOpts := append(opts, serverOptions(logger, metrics)...)
+		return grpc.NewServer(allOpts...)
+	}
 }
 
-func ServeProvider(p Provider) error {
-	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: Handshake,
-		GRPCServer:      grpcServerFactory,
-		VersionedPlugins: map[int]plugin.PluginSet{
-			5: map[string]plugin.Plugin{
-				"provider": &grpcPlugin{
-					providerServer: &GRPCProviderServer{
-						Server: Server{
-							Provider: p,
-						},
-					},
-				},
+// ServeOpt is an option for ServeProvider.
+type ServeOpt func(*serveConfig)
+
+type serveConfig struct {
+	flags           bool
+	providerName    string
+	providerAddress string
+	schemaJSON      io.Writer
+	encodeJSON      bool
+	debug           bool
+	debugOutput     io.Writer
+}
+
+// WithFlags makes ServeProvider parse the -schema-json and -debug flags
+// from the command line. Without it the arguments are left to the provider.
+func WithFlags() ServeOpt {
+	return func(conf *serveConfig) {
+		conf.flags = true
+	}
+}
+
+// WithJSONEncoding makes the provider answer with JSON encoded values
+// instead of msgpack, for debugging.
+func WithJSONEncoding() ServeOpt {
+	return func(conf *serveConfig) {
+		conf.encodeJSON = true
+	}
+}
+
+// WithSchemaJSON makes ServeProvider write the provider schema as JSON
+// to w and return instead of serving the plugin.
+func WithSchemaJSON(w io.Writer) ServeOpt {
+	return func(conf *serveConfig) {
+		conf.schemaJSON = w
+	}
+}
+
+// WithProviderName sets the provider name used as the key in the schema
+// JSON output. It defaults to the name derived from the binary.
+func WithProviderName(name string) ServeOpt {
+	return func(conf *serveConfig) {
+		conf.providerName = name
+	}
+}
+
+// WithProviderAddress sets the fully qualified address Terraform knows the
+// provider by, such as registry.terraform.io/acme/widget, used as the key
+// of TF_REATTACH_PROVIDERS in debug mode. It defaults to the provider name
+// in the hashicorp namespace of the public registry.
+func WithProviderAddress(addr string) ServeOpt {
+	return func(conf *serveConfig) {
+		conf.providerAddress = addr
+	}
+}
+
+// providerNameFromBinary derives the provider name from a binary path
+// such as terraform-provider-tls_v2.0.0.
+func providerNameFromBinary(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, ".exe")
+	name = strings.TrimPrefix(name, "terraform-provider-")
+	if i := strings.Index(name, "_"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func newServeConfig(args []string, opts []ServeOpt) (*serveConfig, error) {
+	conf := &serveConfig{}
+	for _, opt := range opts {
+		opt(conf)
+	}
+
+	if conf.flags {
+		fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+		schemaJSON := fs.Bool("schema-json", false, "write the provider schema as JSON to stdout and exit")
+		fs.BoolVar(&conf.debug, "debug", false, "serve in-process for debuggers and print the TF_REATTACH_PROVIDERS value to attach Terraform")
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if *schemaJSON && conf.schemaJSON == nil {
+			conf.schemaJSON = os.Stdout
+		}
+	}
+	if conf.providerName == "" {
+		conf.providerName = providerNameFromBinary(os.Args[0])
+	}
+	if conf.providerAddress == "" {
+		conf.providerAddress = providerAddress(conf.providerName)
+	}
+	if conf.debugOutput == nil {
+		conf.debugOutput = os.Stdout
+	}
+
+	return conf, nil
+}
+
+func ServeProvider(p Provider, opts ...ServeOpt) error {
+	conf, err := newServeConfig(os.Args[1:], opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return serveProvider(conf, newGRPCProviderServer(p, conf), newGRPCProviderServerV6(p, conf))
+}
+
+// ServeProviders serves several providers as one through a MuxServer,
+// failing if their type names overlap or their provider schemas differ.
+func ServeProviders(providers []Provider, opts ...ServeOpt) error {
+	conf, err := newServeConfig(os.Args[1:], opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	mux, err := newMuxServer(providers, conf)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return serveProvider(conf, mux, nil)
+}
+
+// serveProvider serves providerServer on protocol version 5 and, unless it
+// is nil, providerServerV6 on version 6, letting go-plugin negotiate the
+// version with Terraform. The schema JSON and debug modes use version 5.
+func serveProvider(conf *serveConfig, providerServer pb.ProviderServer, providerServerV6 pb6.ProviderServer) error {
+	if conf.schemaJSON != nil {
+		return writeSchemaJSON(context.Background(), conf.schemaJSON, conf.providerName, providerServer)
+	}
+
+	if conf.debug {
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+		return serveDebug(ctx, conf, providerServer)
+	}
+
+	plugins := map[int]plugin.PluginSet{
+		5: map[string]plugin.Plugin{
+			"provider": &grpcPlugin{
+				providerServer: providerServer,
 			},
 		},
+	}
+	if providerServerV6 != nil {
+		plugins[6] = map[string]plugin.Plugin{
+			"provider": &grpcPluginV6{
+				providerServer: providerServerV6,
+			},
+		}
+	}
+
+	logger := newLogger(true)
+	metrics := metricsFromEnv()
+	defer metrics.dump()
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig:  Handshake,
+		GRPCServer:       grpcServerFactory(logger, metrics),
+		Logger:           logger,
+		VersionedPlugins: plugins,
 	})
 
 	return nil
 }
 
+func newGRPCProviderServer(p Provider, conf *serveConfig) *GRPCProviderServer {
+	return &GRPCProviderServer{
+		Server: Server{
+			Provider:   p,
+			EncodeJSON: conf.encodeJSON,
+		},
+	}
+}
+
 type grpcPlugin struct {
 	plugin.Plugin
-	providerServer *GRPCProviderServer
+	providerServer pb.ProviderServer
 }
 
 func (p *grpcPlugin) GRPCServer(broker *plugin.GRPCBroker, s *grpc.Server) error {
@@ -64,14 +227,29 @@ func (p *grpcPlugin) GRPCServer(broker *plugin.GRPCBroker, s *grpc.Server) error
 }
 
 func (p *grpcPlugin) GRPCClient(ctx context.Context, broker *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
-	panic("not implemented")
+	return NewProviderClient(c), nil
 }
 
 type GRPCProviderServer struct {
 	Server Server
+
+	// the schema response is built once, as it is the same for every call
+	schemaOnce sync.Once
+	schema     *pb.GetProviderSchema_Response
+	schemaErr  error
 }
 
 func (s *GRPCProviderServer) GetSchema(ctx context.Context, req *pb.GetProviderSchema_Request) (*pb.GetProviderSchema_Response, error) {
+	s.schemaOnce.Do(func() {
+		s.schema, s.schemaErr = s.buildSchema(ctx)
+	})
+	if s.schemaErr != nil {
+		return nil, errors.WithStack(s.schemaErr)
+	}
+	return s.schema, nil
+}
+
+func (s *GRPCProviderServer) buildSchema(ctx context.Context) (*pb.GetProviderSchema_Response, error) {
 	resp, err := s.Server.GetSchema(ctx, &GetSchemaRequest{})
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -101,7 +279,7 @@ func (s *GRPCProviderServer) GetSchema(ctx context.Context, req *pb.GetProviderS
 
 func (s *GRPCProviderServer) PrepareProviderConfig(ctx context.Context, req *pb.PrepareProviderConfig_Request) (*pb.PrepareProviderConfig_Response, error) {
 	resp, err := s.Server.PrepareProviderConfig(ctx, &PrepareProviderConfigRequest{
-		Config: req.Config.Msgpack,
+		Config: dynamicValue(req.Config),
 	})
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -119,7 +297,7 @@ func (s *GRPCProviderServer) PrepareProviderConfig(ctx context.Context, req *pb.
 func (s *GRPCProviderServer) ValidateResourceTypeConfig(ctx context.Context, req *pb.ValidateResourceTypeConfig_Request) (*pb.ValidateResourceTypeConfig_Response, error) {
 	resp, err := s.Server.ValidateResourceTypeConfig(ctx, &ValidateResourceTypeConfigRequest{
 		TypeName: req.TypeName,
-		Config:   req.Config.Msgpack,
+		Config:   dynamicValue(req.Config),
 	})
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -136,7 +314,7 @@ func (s *GRPCProviderServer) ValidateResourceTypeConfig(ctx context.Context, req
 func (s *GRPCProviderServer) ValidateDataSourceConfig(ctx context.Context, req *pb.ValidateDataSourceConfig_Request) (*pb.ValidateDataSourceConfig_Response, error) {
 	resp, err := s.Server.ValidateDataSourceConfig(ctx, &ValidateDataSourceConfigRequest{
 		TypeName: req.TypeName,
-		Config:   req.Config.Msgpack,
+		Config:   dynamicValue(req.Config),
 	})
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -150,13 +328,32 @@ func (s *GRPCProviderServer) ValidateDataSourceConfig(ctx context.Context, req *
 	}, nil
 }
 
-func (s *GRPCProviderServer) UpgradeResourceState(context.Context, *pb.UpgradeResourceState_Request) (*pb.UpgradeResourceState_Response, error) {
-	panic("UpgradeResourceState not implemented")
+func (s *GRPCProviderServer) UpgradeResourceState(ctx context.Context, req *pb.UpgradeResourceState_Request) (*pb.UpgradeResourceState_Response, error) {
+	upReq := &UpgradeResourceStateRequest{
+		TypeName: req.TypeName,
+		Version:  int(req.Version),
+	}
+	if req.RawState != nil {
+		upReq.RawStateJSON = req.RawState.Json
+		upReq.RawStateFlatmap = req.RawState.Flatmap
+	}
+	resp, err := s.Server.UpgradeResourceState(ctx, upReq)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pbDiagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb.UpgradeResourceState_Response{
+		Diagnostics:   pbDiags,
+		UpgradedState: pbDynamicValue(resp.UpgradedState),
+	}, nil
 }
 
 func (s *GRPCProviderServer) Configure(ctx context.Context, req *pb.Configure_Request) (*pb.Configure_Response, error) {
 	resp, err := s.Server.Configure(ctx, &ConfigureRequest{
-		Config:           req.Config.Msgpack,
+		Config:           dynamicValue(req.Config),
 		TerraformVersion: req.TerraformVersion,
 	})
 	if err != nil {
@@ -174,7 +371,7 @@ func (s *GRPCProviderServer) Configure(ctx context.Context, req *pb.Configure_Re
 func (s *GRPCProviderServer) ReadResource(ctx context.Context, req *pb.ReadResource_Request) (*pb.ReadResource_Response, error) {
 	resp, err := s.Server.ReadResource(ctx, &ReadResourceRequest{
 		TypeName:     req.TypeName,
-		CurrentState: req.CurrentState.Msgpack,
+		CurrentState: dynamicValue(req.CurrentState),
 	})
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -192,9 +389,9 @@ func (s *GRPCProviderServer) ReadResource(ctx context.Context, req *pb.ReadResou
 func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *pb.PlanResourceChange_Request) (*pb.PlanResourceChange_Response, error) {
 	resp, err := s.Server.PlanResourceChange(ctx, &PlanResourceChangeRequest{
 		TypeName:         req.TypeName,
-		PriorState:       req.PriorState.Msgpack,
-		Config:           req.Config.Msgpack,
-		ProposedNewState: req.ProposedNewState.Msgpack,
+		PriorState:       dynamicValue(req.PriorState),
+		Config:           dynamicValue(req.Config),
+		ProposedNewState: dynamicValue(req.ProposedNewState),
 	})
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -218,8 +415,8 @@ func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *pb.Pla
 func (s *GRPCProviderServer) ApplyResourceChange(ctx context.Context, req *pb.ApplyResourceChange_Request) (*pb.ApplyResourceChange_Response, error) {
 	resp, err := s.Server.ApplyResourceChange(ctx, &ApplyResourceChangeRequest{
 		TypeName:     req.TypeName,
-		PlannedState: req.PlannedState.Msgpack,
-		PriorState:   req.PriorState.Msgpack,
+		PlannedState: dynamicValue(req.PlannedState),
+		PriorState:   dynamicValue(req.PriorState),
 	})
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -234,14 +431,35 @@ func (s *GRPCProviderServer) ApplyResourceChange(ctx context.Context, req *pb.Ap
 	}, nil
 }
 
-func (s *GRPCProviderServer) ImportResourceState(context.Context, *pb.ImportResourceState_Request) (*pb.ImportResourceState_Response, error) {
-	panic("ImportResourceState not implemented")
+func (s *GRPCProviderServer) ImportResourceState(ctx context.Context, req *pb.ImportResourceState_Request) (*pb.ImportResourceState_Response, error) {
+	resp, err := s.Server.ImportResourceState(ctx, &ImportResourceStateRequest{
+		TypeName: req.TypeName,
+		ID:       req.Id,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pbDiagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	imported := make([]*pb.ImportResourceState_ImportedResource, len(resp.ImportedResources))
+	for i, r := range resp.ImportedResources {
+		imported[i] = &pb.ImportResourceState_ImportedResource{
+			TypeName: r.TypeName,
+			State:    pbDynamicValue(r.State),
+		}
+	}
+	return &pb.ImportResourceState_Response{
+		Diagnostics:       pbDiags,
+		ImportedResources: imported,
+	}, nil
 }
 
 func (s *GRPCProviderServer) ReadDataSource(ctx context.Context, req *pb.ReadDataSource_Request) (*pb.ReadDataSource_Response, error) {
 	resp, err := s.Server.ReadDataSource(ctx, &ReadDataSourceRequest{
 		TypeName: req.TypeName,
-		Config:   req.Config.Msgpack,
+		Config:   dynamicValue(req.Config),
 	})
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -0,0 +1,288 @@
+package sdk // import "github.com/hashicorp/terraform-plugin-sdk"
+
+import (
+	"context"
+	"sync"
+
+	plugin "github.com/hashicorp/go-plugin"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+
+	pb6 "github.com/hashicorp/terraform-plugin-sdk/tfplugin6"
+)
+
+func newGRPCProviderServerV6(p Provider, conf *serveConfig) *GRPCProviderServerV6 {
+	return &GRPCProviderServerV6{
+		Server: Server{
+			Provider:   p,
+			EncodeJSON: conf.encodeJSON,
+		},
+	}
+}
+
+type grpcPluginV6 struct {
+	plugin.Plugin
+	providerServer pb6.ProviderServer
+}
+
+func (p *grpcPluginV6) GRPCServer(broker *plugin.GRPCBroker, s *grpc.Server) error {
+	pb6.RegisterProviderServer(s, p.providerServer)
+	return nil
+}
+
+func (p *grpcPluginV6) GRPCClient(ctx context.Context, broker *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return nil, errors.New("protocol version 6 clients are not supported")
+}
+
+// GRPCProviderServerV6 exposes a Server on protocol version 6.
+type GRPCProviderServerV6 struct {
+	Server Server
+
+	// the schema response is built once, as it is the same for every call
+	schemaOnce sync.Once
+	schema     *pb6.GetProviderSchema_Response
+	schemaErr  error
+}
+
+func (s *GRPCProviderServerV6) GetProviderSchema(ctx context.Context, req *pb6.GetProviderSchema_Request) (*pb6.GetProviderSchema_Response, error) {
+	s.schemaOnce.Do(func() {
+		s.schema, s.schemaErr = s.buildSchema(ctx)
+	})
+	if s.schemaErr != nil {
+		return nil, errors.WithStack(s.schemaErr)
+	}
+	return s.schema, nil
+}
+
+func (s *GRPCProviderServerV6) buildSchema(ctx context.Context) (*pb6.GetProviderSchema_Response, error) {
+	resp, err := s.Server.GetSchema(ctx, &GetSchemaRequest{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	p, err := pb6Schema(resp.Provider)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	resources, err := pb6MapSchema(resp.ResourceSchemas)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	dataSources, err := pb6MapSchema(resp.DataSourceSchemas)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &pb6.GetProviderSchema_Response{
+		Provider:          p,
+		DataSourceSchemas: dataSources,
+		ResourceSchemas:   resources,
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) ValidateProviderConfig(ctx context.Context, req *pb6.ValidateProviderConfig_Request) (*pb6.ValidateProviderConfig_Response, error) {
+	resp, err := s.Server.PrepareProviderConfig(ctx, &PrepareProviderConfigRequest{
+		Config: dynamicValue6(req.Config),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pb6Diagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb6.ValidateProviderConfig_Response{
+		Diagnostics: pbDiags,
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) ValidateResourceConfig(ctx context.Context, req *pb6.ValidateResourceConfig_Request) (*pb6.ValidateResourceConfig_Response, error) {
+	resp, err := s.Server.ValidateResourceTypeConfig(ctx, &ValidateResourceTypeConfigRequest{
+		TypeName: req.TypeName,
+		Config:   dynamicValue6(req.Config),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pb6Diagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb6.ValidateResourceConfig_Response{
+		Diagnostics: pbDiags,
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) ValidateDataResourceConfig(ctx context.Context, req *pb6.ValidateDataResourceConfig_Request) (*pb6.ValidateDataResourceConfig_Response, error) {
+	resp, err := s.Server.ValidateDataSourceConfig(ctx, &ValidateDataSourceConfigRequest{
+		TypeName: req.TypeName,
+		Config:   dynamicValue6(req.Config),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pb6Diagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb6.ValidateDataResourceConfig_Response{
+		Diagnostics: pbDiags,
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) UpgradeResourceState(ctx context.Context, req *pb6.UpgradeResourceState_Request) (*pb6.UpgradeResourceState_Response, error) {
+	upReq := &UpgradeResourceStateRequest{
+		TypeName: req.TypeName,
+		Version:  int(req.Version),
+	}
+	if req.RawState != nil {
+		upReq.RawStateJSON = req.RawState.Json
+		upReq.RawStateFlatmap = req.RawState.Flatmap
+	}
+	resp, err := s.Server.UpgradeResourceState(ctx, upReq)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pb6Diagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb6.UpgradeResourceState_Response{
+		Diagnostics:   pbDiags,
+		UpgradedState: pb6DynamicValue(resp.UpgradedState),
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) ConfigureProvider(ctx context.Context, req *pb6.ConfigureProvider_Request) (*pb6.ConfigureProvider_Response, error) {
+	resp, err := s.Server.Configure(ctx, &ConfigureRequest{
+		Config:           dynamicValue6(req.Config),
+		TerraformVersion: req.TerraformVersion,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pb6Diagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb6.ConfigureProvider_Response{
+		Diagnostics: pbDiags,
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) ReadResource(ctx context.Context, req *pb6.ReadResource_Request) (*pb6.ReadResource_Response, error) {
+	resp, err := s.Server.ReadResource(ctx, &ReadResourceRequest{
+		TypeName:     req.TypeName,
+		CurrentState: dynamicValue6(req.CurrentState),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pb6Diagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb6.ReadResource_Response{
+		Diagnostics: pbDiags,
+		NewState:    pb6DynamicValue(resp.NewState),
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) PlanResourceChange(ctx context.Context, req *pb6.PlanResourceChange_Request) (*pb6.PlanResourceChange_Response, error) {
+	resp, err := s.Server.PlanResourceChange(ctx, &PlanResourceChangeRequest{
+		TypeName:         req.TypeName,
+		PriorState:       dynamicValue6(req.PriorState),
+		Config:           dynamicValue6(req.Config),
+		ProposedNewState: dynamicValue6(req.ProposedNewState),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pb6Diagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbRequiresReplace, err := pb6AttributePaths(resp.RequiresReplace)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb6.PlanResourceChange_Response{
+		Diagnostics:     pbDiags,
+		PlannedState:    pb6DynamicValue(resp.PlannedState),
+		RequiresReplace: pbRequiresReplace,
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) ApplyResourceChange(ctx context.Context, req *pb6.ApplyResourceChange_Request) (*pb6.ApplyResourceChange_Response, error) {
+	resp, err := s.Server.ApplyResourceChange(ctx, &ApplyResourceChangeRequest{
+		TypeName:     req.TypeName,
+		PlannedState: dynamicValue6(req.PlannedState),
+		PriorState:   dynamicValue6(req.PriorState),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pb6Diagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb6.ApplyResourceChange_Response{
+		Diagnostics: pbDiags,
+		NewState:    pb6DynamicValue(resp.NewState),
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) ImportResourceState(ctx context.Context, req *pb6.ImportResourceState_Request) (*pb6.ImportResourceState_Response, error) {
+	resp, err := s.Server.ImportResourceState(ctx, &ImportResourceStateRequest{
+		TypeName: req.TypeName,
+		ID:       req.Id,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pb6Diagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	imported := make([]*pb6.ImportResourceState_ImportedResource, len(resp.ImportedResources))
+	for i, r := range resp.ImportedResources {
+		imported[i] = &pb6.ImportResourceState_ImportedResource{
+			TypeName: r.TypeName,
+			State:    pb6DynamicValue(r.State),
+		}
+	}
+	return &pb6.ImportResourceState_Response{
+		Diagnostics:       pbDiags,
+		ImportedResources: imported,
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) ReadDataSource(ctx context.Context, req *pb6.ReadDataSource_Request) (*pb6.ReadDataSource_Response, error) {
+	resp, err := s.Server.ReadDataSource(ctx, &ReadDataSourceRequest{
+		TypeName: req.TypeName,
+		Config:   dynamicValue6(req.Config),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pb6Diagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb6.ReadDataSource_Response{
+		Diagnostics: pbDiags,
+		State:       pb6DynamicValue(resp.State),
+	}, nil
+}
+
+func (s *GRPCProviderServerV6) StopProvider(ctx context.Context, req *pb6.StopProvider_Request) (*pb6.StopProvider_Response, error) {
+	err := s.Server.Stop(ctx)
+	if err != nil {
+		return &pb6.StopProvider_Response{
+			Error: err.Error(),
+		}, nil
+	}
+	return &pb6.StopProvider_Response{}, nil
+}
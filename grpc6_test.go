@@ -0,0 +1,129 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+	pb6 "github.com/hashicorp/terraform-plugin-sdk/tfplugin6"
+)
+
+// testNestedResource has a list of nested port attributes and keeps its
+// state as a value.
+type testNestedResource struct {
+	state cty.Value
+}
+
+func (r *testNestedResource) Schema() Schema {
+	return Schema{
+		Block: Block{
+			Attributes: []Attribute{
+				{Name: "id", Type: cty.String, Computed: true},
+				{
+					Name:     "ports",
+					Optional: true,
+					NestedType: &Object{
+						Nesting: NestingList,
+						Attributes: []Attribute{
+							{Name: "port", Type: cty.Number, Required: true},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *testNestedResource) Read(context.Context) error { return nil }
+
+func (r *testNestedResource) Create(context.Context) error {
+	r.state = cty.ObjectVal(map[string]cty.Value{
+		"id":    cty.StringVal("test-id"),
+		"ports": r.state.GetAttr("ports"),
+	})
+	return nil
+}
+
+func (r *testNestedResource) Delete(context.Context) error { return nil }
+
+func (r *testNestedResource) UnmarshalState(v cty.Value) error {
+	r.state = v
+	return nil
+}
+
+func (r *testNestedResource) MarshalState() (cty.Value, error) {
+	return r.state, nil
+}
+
+func newTestNestedProvider() *testProvider {
+	return &testProvider{
+		resources: map[string]func() Resource{
+			"test_nested": func() Resource { return &testNestedResource{} },
+		},
+	}
+}
+
+func TestGRPCProviderServerV6NestedAttributes(t *testing.T) {
+	ctx := context.Background()
+	conf := &serveConfig{}
+
+	v6, err := newGRPCProviderServerV6(newTestNestedProvider(), conf).GetProviderSchema(ctx, &pb6.GetProviderSchema_Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports := v6.ResourceSchemas["test_nested"].Block.Attributes[1]
+	if ports.Name != "ports" || ports.Type != nil || ports.NestedType == nil {
+		t.Fatalf("expected ports to have nested attributes, got %v", ports)
+	}
+	if ports.NestedType.Nesting != pb6.Schema_Object_LIST || ports.NestedType.Attributes[0].Name != "port" {
+		t.Fatalf("unexpected nested type %v", ports.NestedType)
+	}
+
+	v5, err := newGRPCProviderServer(newTestNestedProvider(), conf).GetSchema(ctx, &pb.GetProviderSchema_Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports5 := v5.ResourceSchemas["test_nested"].Block.Attributes[1]
+	if expected := `["list",["object",{"port":"number"}]]`; string(ports5.Type) != expected {
+		t.Fatalf("expected protocol 5 type %s, got %s", expected, ports5.Type)
+	}
+}
+
+func TestGRPCProviderServerV6ApplyResourceChange(t *testing.T) {
+	s := newGRPCProviderServerV6(newTestNestedProvider(), &serveConfig{})
+	testConfigure(t, &s.Server)
+	ty := (&testNestedResource{}).Schema().Block.ImpliedType()
+
+	planned := cty.ObjectVal(map[string]cty.Value{
+		"id": cty.UnknownVal(cty.String),
+		"ports": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(80)}),
+		}),
+	})
+	resp, err := s.ApplyResourceChange(context.Background(), &pb6.ApplyResourceChange_Request{
+		TypeName:     "test_nested",
+		PriorState:   pb6DynamicValue(testMsgpack(t, cty.NullVal(ty))),
+		PlannedState: pb6DynamicValue(testMsgpack(t, planned)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics %v", resp.Diagnostics)
+	}
+
+	state, err := msgpack.Unmarshal(resp.NewState.Msgpack, ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"id":    cty.StringVal("test-id"),
+		"ports": planned.GetAttr("ports"),
+	})
+	if !state.RawEquals(expected) {
+		t.Fatalf("expected %#v, got %#v", expected, state)
+	}
+}
@@ -0,0 +1,168 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	plugin "github.com/hashicorp/go-plugin"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+func ServeProvisioner(p Provisioner) error {
+	logger := newLogger(true)
+	metrics := metricsFromEnv()
+	defer metrics.dump()
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		GRPCServer:      grpcServerFactory(logger, metrics),
+		Logger:          logger,
+		VersionedPlugins: map[int]plugin.PluginSet{
+			5: map[string]plugin.Plugin{
+				"provisioner": &grpcProvisionerPlugin{
+					provisionerServer: &GRPCProvisionerServer{
+						Server: ProvisionerServer{
+							Provisioner: p,
+						},
+					},
+				},
+			},
+		},
+	})
+
+	return nil
+}
+
+type grpcProvisionerPlugin struct {
+	plugin.Plugin
+	provisionerServer *GRPCProvisionerServer
+}
+
+func (p *grpcProvisionerPlugin) GRPCServer(broker *plugin.GRPCBroker, s *grpc.Server) error {
+	pb.RegisterProvisionerServer(s, p.provisionerServer)
+	return nil
+}
+
+func (p *grpcProvisionerPlugin) GRPCClient(ctx context.Context, broker *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return pb.NewProvisionerClient(c), nil
+}
+
+type GRPCProvisionerServer struct {
+	Server ProvisionerServer
+}
+
+func (s *GRPCProvisionerServer) GetSchema(ctx context.Context, req *pb.GetProvisionerSchema_Request) (*pb.GetProvisionerSchema_Response, error) {
+	resp, err := s.Server.GetSchema(ctx, &GetProvisionerSchemaRequest{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	schema, err := pbSchema(resp.Provisioner)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &pb.GetProvisionerSchema_Response{
+		Provisioner: schema,
+	}, nil
+}
+
+func (s *GRPCProvisionerServer) ValidateProvisionerConfig(ctx context.Context, req *pb.ValidateProvisionerConfig_Request) (*pb.ValidateProvisionerConfig_Response, error) {
+	resp, err := s.Server.ValidateProvisionerConfig(ctx, &ValidateProvisionerConfigRequest{
+		Config: dynamicValue(req.Config),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pbDiags, err := pbDiagnostics(resp.Diagnostics)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb.ValidateProvisionerConfig_Response{
+		Diagnostics: pbDiags,
+	}, nil
+}
+
+func (s *GRPCProvisionerServer) ProvisionResource(req *pb.ProvisionResource_Request, srv pb.Provisioner_ProvisionResourceServer) error {
+	output := &provisionOutput{
+		srv: srv,
+	}
+	resp, err := s.Server.ProvisionResource(srv.Context(), &ProvisionResourceRequest{
+		Config:     dynamicValue(req.Config),
+		Connection: dynamicValue(req.Connection),
+	}, output)
+	// send any trailing output even if provisioning failed
+	flushErr := output.flush()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if flushErr != nil {
+		return errors.WithStack(flushErr)
+	}
+
+	if len(resp.Diagnostics) == 0 {
+		return nil
+	}
+	pbDiags, err := pbDiagnostics(resp.Diagnostics)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return srv.Send(&pb.ProvisionResource_Response{
+		Diagnostics: pbDiags,
+	})
+}
+
+func (s *GRPCProvisionerServer) Stop(ctx context.Context, req *pb.Stop_Request) (*pb.Stop_Response, error) {
+	err := s.Server.Stop(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb.Stop_Response{}, nil
+}
+
+// provisionOutput streams everything written to it to Terraform as one
+// response per line.
+type provisionOutput struct {
+	srv pb.Provisioner_ProvisionResourceServer
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *provisionOutput) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(w.buf.Next(i + 1))
+		err := w.srv.Send(&pb.ProvisionResource_Response{
+			Output: line[:len(line)-1],
+		})
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+}
+
+// flush sends any output not terminated by a newline.
+func (w *provisionOutput) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	line := w.buf.String()
+	w.buf.Reset()
+	return w.srv.Send(&pb.ProvisionResource_Response{
+		Output: line,
+	})
+}
@@ -0,0 +1,92 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/grpc"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+type testProvisioner struct {
+	Command string
+}
+
+func (p *testProvisioner) Schema() Schema {
+	return Schema{
+		Block: Block{
+			Attributes: []Attribute{
+				{Name: "command", Type: cty.String, Required: true},
+			},
+		},
+	}
+}
+
+func (p *testProvisioner) UnmarshalState(v cty.Value) error {
+	p.Command = v.GetAttr("command").AsString()
+	return nil
+}
+
+func (p *testProvisioner) MarshalState() (cty.Value, error) {
+	return cty.ObjectVal(map[string]cty.Value{"command": cty.StringVal(p.Command)}), nil
+}
+
+func (p *testProvisioner) Provision(ctx context.Context, conn map[string]string, output io.Writer) error {
+	fmt.Fprintf(output, "connecting to %s\n", conn["host"])
+	fmt.Fprintf(output, "running %s\nexit ", p.Command)
+	fmt.Fprint(output, "1")
+	return errors.New("command failed")
+}
+
+type testProvisionStream struct {
+	grpc.ServerStream
+	responses []*pb.ProvisionResource_Response
+}
+
+func (s *testProvisionStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *testProvisionStream) Send(resp *pb.ProvisionResource_Response) error {
+	s.responses = append(s.responses, resp)
+	return nil
+}
+
+func TestGRPCProvisionerServerProvisionResource(t *testing.T) {
+	s := &GRPCProvisionerServer{
+		Server: ProvisionerServer{
+			Provisioner: &testProvisioner{},
+		},
+	}
+
+	config := cty.ObjectVal(map[string]cty.Value{"command": cty.StringVal("uptime")})
+	conn := cty.MapVal(map[string]cty.Value{"host": cty.StringVal("10.0.0.1")})
+	stream := &testProvisionStream{}
+
+	err := s.ProvisionResource(&pb.ProvisionResource_Request{
+		Config:     &pb.DynamicValue{Msgpack: testMsgpack(t, config).MsgPack},
+		Connection: &pb.DynamicValue{Msgpack: testMsgpack(t, conn).MsgPack},
+	}, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output []string
+	for _, resp := range stream.responses[:len(stream.responses)-1] {
+		output = append(output, resp.Output)
+	}
+	expected := []string{"connecting to 10.0.0.1", "running uptime", "exit 1"}
+	if fmt.Sprint(output) != fmt.Sprint(expected) {
+		t.Fatalf("expected output %q, got %q", expected, output)
+	}
+
+	diags := stream.responses[len(stream.responses)-1].Diagnostics
+	if len(diags) != 1 || diags[0].Summary != "command failed" {
+		t.Fatalf("expected provision error diagnostic, got %v", diags)
+	}
+}
@@ -0,0 +1,207 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+const (
+	defaultHTTPMaxRetries = 4
+	httpMinBackoff        = time.Second
+	httpMaxBackoff        = 30 * time.Second
+)
+
+// sensitiveHeaders are the headers whose values are not logged.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+type terraformVersionContextKey struct{}
+
+// withTerraformVersion returns a context carrying the Terraform version
+// passed to Configure, for NewHTTPClient.
+func withTerraformVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, terraformVersionContextKey{}, version)
+}
+
+func terraformVersionFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(terraformVersionContextKey{}).(string)
+	return v
+}
+
+// HTTPClientOpt is an option for NewHTTPClient.
+type HTTPClientOpt func(*httpTransport)
+
+// WithUserAgentProduct appends product, such as terraform-provider-tls/2.0.0,
+// to the user agent.
+func WithUserAgentProduct(product string) HTTPClientOpt {
+	return func(t *httpTransport) {
+		t.userAgent += " " + product
+	}
+}
+
+// WithMaxRetries sets how many times a request is retried, 4 by default.
+func WithMaxRetries(n int) HTTPClientOpt {
+	return func(t *httpTransport) {
+		t.maxRetries = n
+	}
+}
+
+// NewHTTPClient returns a client for provider code to call APIs with, based
+// on go-cleanhttp. Its transport logs requests and responses at debug level
+// with sensitive headers redacted, sets a user agent naming the Terraform
+// version passed to Configure, and retries idempotent requests answered with
+// 429 or a 5xx status code, backing off exponentially or as long as the
+// Retry-After header asks.
+//
+// The logger, Terraform version and clock are taken from ctx, which is
+// usually the context passed to Configure. The client outlives ctx.
+func NewHTTPClient(ctx context.Context, opts ...HTTPClientOpt) *http.Client {
+	userAgent := "HashiCorp Terraform"
+	if v := terraformVersionFromContext(ctx); v != "" {
+		userAgent += "/" + v
+	}
+	userAgent += " (+https://www.terraform.io)"
+
+	t := &httpTransport{
+		next:       cleanhttp.DefaultPooledTransport(),
+		logger:     Logger(ctx),
+		clock:      clockFromContext(ctx),
+		userAgent:  userAgent,
+		maxRetries: defaultHTTPMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return &http.Client{Transport: t}
+}
+
+type httpTransport struct {
+	next       http.RoundTripper
+	logger     hclog.Logger
+	clock      Clock
+	userAgent  string
+	maxRetries int
+}
+
+func (t *httpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	logger := t.logger
+	if l, ok := req.Context().Value(loggerContextKey{}).(hclog.Logger); ok {
+		// prefer the logger of the RPC making the request
+		logger = l
+	}
+
+	// round trippers must not modify the request
+	req = req.Clone(req.Context())
+	if ua := req.Header.Get("User-Agent"); ua != "" {
+		req.Header.Set("User-Agent", ua+" "+t.userAgent)
+	} else {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		logger.Debug("sending HTTP request", "method", req.Method, "url", req.URL.String(), "attempt", attempt+1, "headers", redactHeaders(req.Header))
+		start := t.clock.Now()
+		resp, err := t.next.RoundTrip(req)
+		if err != nil {
+			logger.Debug("HTTP request failed", "method", req.Method, "url", req.URL.String(), "error", err)
+			return nil, err
+		}
+		logger.Debug("received HTTP response", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "duration", t.clock.Now().Sub(start), "headers", redactHeaders(resp.Header))
+
+		if attempt >= t.maxRetries || !retryableStatus(resp.StatusCode) || !replayable(req) {
+			return resp, nil
+		}
+
+		wait := retryAfter(resp, backoff(attempt))
+		logger.Debug("retrying HTTP request", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "wait", wait)
+		resp.Body.Close()
+		select {
+		case <-t.clock.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryableStatus reports whether a response with the status code is worth
+// retrying.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || (code >= 500 && code != http.StatusNotImplemented)
+}
+
+// replayable reports whether req is idempotent and its body, if any, can
+// be sent again.
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// backoff returns the exponential backoff before the retry following
+// attempt.
+func backoff(attempt int) time.Duration {
+	d := httpMinBackoff << uint(attempt)
+	if d <= 0 || d > httpMaxBackoff {
+		return httpMaxBackoff
+	}
+	return d
+}
+
+// retryAfter returns the wait asked for by the Retry-After header of resp
+// in seconds, or def if there is none.
+func retryAfter(resp *http.Response, def time.Duration) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return def
+	}
+	d := time.Duration(secs) * time.Second
+	if d > httpMaxBackoff {
+		return httpMaxBackoff
+	}
+	return d
+}
+
+// redactHeaders formats h for logging with the values of sensitive headers
+// masked.
+func redactHeaders(h http.Header) string {
+	names := make([]string, 0, len(h))
+	for n := range h {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, n := range names {
+		v := strings.Join(h[n], ", ")
+		if sensitiveHeaders[http.CanonicalHeaderKey(n)] {
+			v = redactedValue
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", n, v))
+	}
+	return strings.Join(parts, "; ")
+}
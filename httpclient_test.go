@@ -0,0 +1,190 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+// testHTTPServer answers with the status codes in turn, then 200, and
+// records the requests it received.
+type testHTTPServer struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (s *testHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "7")
+		}
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Set-Cookie", "session=secret-session")
+	w.Write([]byte("ok"))
+}
+
+func TestHTTPClientRetries(t *testing.T) {
+	handler := &testHTTPServer{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusBadGateway}}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	clock := newFakeClock()
+	ctx := WithClock(withTerraformVersion(context.Background(), "0.12.0"), clock)
+	c := NewHTTPClient(ctx, WithUserAgentProduct("terraform-provider-test/1.0.0"))
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "custom")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if len(handler.requests) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(handler.requests))
+	}
+	for _, body := range handler.bodies {
+		if body != "payload" {
+			t.Fatalf("expected the body to be sent again, got %q", body)
+		}
+	}
+	expectedUA := "custom HashiCorp Terraform/0.12.0 (+https://www.terraform.io) terraform-provider-test/1.0.0"
+	if ua := handler.requests[0].Header.Get("User-Agent"); ua != expectedUA {
+		t.Fatalf("expected user agent %q, got %q", expectedUA, ua)
+	}
+
+	// exponential backoff, except for the Retry-After of the 429
+	expected := []time.Duration{time.Second, 7 * time.Second, 4 * time.Second}
+	if len(clock.waits) != len(expected) {
+		t.Fatalf("expected waits %v, got %v", expected, clock.waits)
+	}
+	for i, d := range expected {
+		if clock.waits[i] != d {
+			t.Fatalf("expected waits %v, got %v", expected, clock.waits)
+		}
+	}
+}
+
+func TestHTTPClientNoRetry(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodPatch} {
+		handler := &testHTTPServer{statuses: []int{http.StatusServiceUnavailable}}
+		srv := httptest.NewServer(handler)
+
+		clock := newFakeClock()
+		c := NewHTTPClient(WithClock(context.Background(), clock))
+		req, err := http.NewRequest(method, srv.URL, strings.NewReader("payload"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		srv.Close()
+
+		if resp.StatusCode != http.StatusServiceUnavailable || len(handler.requests) != 1 {
+			t.Fatalf("expected %s not to be retried, got %d after %d requests", method, resp.StatusCode, len(handler.requests))
+		}
+	}
+}
+
+func TestHTTPClientMaxRetries(t *testing.T) {
+	handler := &testHTTPServer{statuses: []int{500, 500, 500}}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	c := NewHTTPClient(WithClock(context.Background(), newFakeClock()), WithMaxRetries(1))
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 500 || len(handler.requests) != 2 {
+		t.Fatalf("expected to give up after one retry, got %d after %d requests", resp.StatusCode, len(handler.requests))
+	}
+}
+
+func TestHTTPClientLogging(t *testing.T) {
+	srv := httptest.NewServer(&testHTTPServer{})
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := hclog.New(&hclog.LoggerOptions{
+		Level:  hclog.Debug,
+		Output: &buf,
+	})
+	c := NewHTTPClient(WithLogger(context.Background(), logger))
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	out := buf.String()
+	for _, secret := range []string{"secret-token", "secret-session"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("expected %s to be redacted, got:\n%s", secret, out)
+		}
+	}
+	for _, expected := range []string{"sending HTTP request", "received HTTP response", "Authorization: (sensitive value)", "status=200"} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected log to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestServerConfigureTerraformVersion(t *testing.T) {
+	var version string
+	p := &testConfigureProvider{
+		testProvider: newTestProvider(),
+		configure: func(ctx context.Context) error {
+			version = terraformVersionFromContext(ctx)
+			return nil
+		},
+	}
+	s := &Server{Provider: p}
+
+	req := testConfigureRequest(t)
+	req.TerraformVersion = "0.12.0"
+	_, err := s.Configure(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "0.12.0" {
+		t.Fatalf("expected Terraform version in context, got %q", version)
+	}
+}
@@ -0,0 +1,83 @@
+package sdk
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultStopTimeout is how long Stop waits for in-flight operations if
+// no timeout is set.
+const defaultStopTimeout = 10 * time.Second
+
+// inflightTracker tracks the contexts of in-flight operations so they can
+// be cancelled when Terraform stops the plugin.
+type inflightTracker struct {
+	mu  sync.Mutex
+	ops map[*inflightOp]struct{}
+}
+
+type inflightOp struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// track returns a context for an operation which is cancelled by
+// cancelAndWait. The returned func must be called once the operation has
+// returned.
+func (t *inflightTracker) track(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	op := &inflightOp{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	t.mu.Lock()
+	if t.ops == nil {
+		t.ops = map[*inflightOp]struct{}{}
+	}
+	t.ops[op] = struct{}{}
+	t.mu.Unlock()
+
+	return ctx, func() {
+		t.mu.Lock()
+		delete(t.ops, op)
+		t.mu.Unlock()
+
+		cancel()
+		close(op.done)
+	}
+}
+
+// cancelAndWait cancels all in-flight operations and waits up to timeout
+// for them to return.
+func (t *inflightTracker) cancelAndWait(ctx context.Context, timeout time.Duration) {
+	t.mu.Lock()
+	ops := make([]*inflightOp, 0, len(t.ops))
+	for op := range t.ops {
+		ops = append(ops, op)
+	}
+	t.mu.Unlock()
+
+	for _, op := range ops {
+		op.cancel()
+	}
+
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for i, op := range ops {
+		select {
+		case <-op.done:
+		case <-timer.C:
+			Logger(ctx).Warn("timed out waiting for in-flight operations to stop", "remaining", len(ops)-i)
+			return
+		case <-ctx.Done():
+			Logger(ctx).Warn("stop cancelled while waiting for in-flight operations", "remaining", len(ops)-i)
+			return
+		}
+	}
+}
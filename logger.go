@@ -0,0 +1,62 @@
+package sdk
+
+import (
+	"context"
+	"log"
+	"os"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+type loggerContextKey struct{}
+
+// WithLogger returns a context carrying l, which Logger returns.
+func WithLogger(ctx context.Context, l hclog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, l)
+}
+
+// Logger returns the logger of the RPC ctx belongs to, annotated with the
+// RPC name, resource type, request ID and Terraform version. Outside of an
+// RPC it returns a logger writing to the standard logger.
+func Logger(ctx context.Context) hclog.Logger {
+	if l, ok := ctx.Value(loggerContextKey{}).(hclog.Logger); ok {
+		return l
+	}
+	return newLogger(false)
+}
+
+// newLogger returns the root logger of the plugin. When served by go-plugin
+// logs are written to stderr as JSON so Terraform can parse them, otherwise
+// they are written as text to the standard logger at the level of TF_LOG.
+func newLogger(jsonFormat bool) hclog.Logger {
+	if jsonFormat {
+		return hclog.New(&hclog.LoggerOptions{
+			Name:       "provider",
+			Level:      hclog.Trace,
+			Output:     os.Stderr,
+			JSONFormat: true,
+		})
+	}
+
+	level := hclog.Info
+	if tfLog := os.Getenv("TF_LOG"); tfLog != "" {
+		level = hclog.LevelFromString(tfLog)
+		if level == hclog.NoLevel {
+			// Terraform treats any other value as TRACE
+			level = hclog.Trace
+		}
+	}
+	return hclog.New(&hclog.LoggerOptions{
+		Name:   "provider",
+		Level:  level,
+		Output: stdLogWriter{},
+	})
+}
+
+// stdLogWriter writes to the current output of the standard logger, which
+// plugintest redirects.
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	return log.Writer().Write(p)
+}
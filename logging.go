@@ -2,33 +2,244 @@ package sdk
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"path"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
+	hclog "github.com/hashicorp/go-hclog"
+	uuid "github.com/hashicorp/go-uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+	pb6 "github.com/hashicorp/terraform-plugin-sdk/tfplugin6"
 )
 
+// LoggingServerInterceptor logs unary RPCs and puts a logger annotated
+// with the request into their context, see Logger.
 func LoggingServerInterceptor() grpc.UnaryServerInterceptor {
-	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		var (
-			err  error
-			resp interface{}
-		)
+	return newRequestLogger(newLogger(false)).unary
+}
+
+// requestLogger derives a logger for every RPC from the plugin's root
+// logger.
+type requestLogger struct {
+	root hclog.Logger
+
+	mu        sync.Mutex
+	tfVersion string
+}
+
+func newRequestLogger(root hclog.Logger) *requestLogger {
+	return &requestLogger{root: root}
+}
+
+func (rl *requestLogger) forRequest(fullMethod string, req interface{}) hclog.Logger {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if v, ok := req.(interface{ GetTerraformVersion() string }); ok {
+		// remembered for the RPCs following Configure
+		rl.tfVersion = v.GetTerraformVersion()
+	}
+
+	args := []interface{}{"rpc", path.Base(fullMethod)}
+	if id, err := uuid.GenerateUUID(); err == nil {
+		args = append(args, "request_id", id)
+	}
+	if tn, ok := req.(interface{ GetTypeName() string }); ok && tn.GetTypeName() != "" {
+		args = append(args, "type", tn.GetTypeName())
+	}
+	if rl.tfVersion != "" {
+		args = append(args, "tf_version", rl.tfVersion)
+	}
+	return rl.root.With(args...)
+}
+
+func (rl *requestLogger) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	logger := rl.forRequest(info.FullMethod, req)
+	ctx = WithLogger(ctx, logger)
 
-		start := time.Now()
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logRequest(logger, start, err)
+	return resp, err
+}
+
+func (rl *requestLogger) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	logger := rl.forRequest(info.FullMethod, nil)
+	ss = &contextServerStream{
+		ServerStream: ss,
+		ctx:          WithLogger(ss.Context(), logger),
+	}
+
+	start := time.Now()
+	err := handler(srv, ss)
+	logRequest(logger, start, err)
+	return err
+}
+
+func logRequest(logger hclog.Logger, start time.Time, err error) {
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		logger.Error("request failed", "elapsed", elapsed, "error", fmt.Sprintf("%+v", err))
+		return
+	}
+	logger.Info("request served", "elapsed", elapsed)
+}
+
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// RecoveryServerInterceptor recovers panics in unary RPCs and answers with
+// an error diagnostic instead of crashing the plugin.
+func RecoveryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		defer func() {
-			elapsed := time.Since(start)
-			elapsed = elapsed.Round(time.Millisecond)
-			method := info.FullMethod
-			err := err
-			log.Printf("[INFO] GRPC: method=%s elapsed=%vs", method, elapsed.Seconds())
-			if err != nil {
-				log.Printf("[ERROR] GRPC: method=%s elapsed=%vs err=%s", method, elapsed.Seconds(), err)
-				log.Printf("[ERROR] GRPC: %+v", err)
+			if r := recover(); r != nil {
+				typeName := ""
+				if tn, ok := req.(interface{ GetTypeName() string }); ok {
+					typeName = tn.GetTypeName()
+				}
+				resp, err = panicResponse(ctx, info.FullMethod, typeName, r)
 			}
 		}()
 
-		resp, err = handler(ctx, req)
-		return resp, err
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamServerInterceptor recovers panics in streaming RPCs and
+// sends an error diagnostic as the final message instead of crashing the
+// plugin.
+func RecoveryStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				var resp interface{}
+				resp, err = panicResponse(ss.Context(), info.FullMethod, "", r)
+				if err == nil {
+					err = ss.SendMsg(resp)
+				}
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+// ServerOptions returns the interceptors every plugin gRPC server should
+// install.
+func ServerOptions() []grpc.ServerOption {
+	return serverOptions(newLogger(false), nil)
+}
+
+func serverOptions(logger hclog.Logger, metrics *Metrics) []grpc.ServerOption {
+	rl := newRequestLogger(logger)
+
+	// recovery runs inside logging, so recovered panics are logged with the
+	// request
+	unary := []grpc.UnaryServerInterceptor{rl.unary, RecoveryServerInterceptor()}
+	stream := []grpc.StreamServerInterceptor{rl.stream, RecoveryStreamServerInterceptor()}
+	if metrics != nil {
+		unary = append([]grpc.UnaryServerInterceptor{metrics.UnaryServerInterceptor()}, unary...)
+		stream = append([]grpc.StreamServerInterceptor{metrics.StreamServerInterceptor()}, stream...)
+	}
+	if enableTracing() {
+		unary = append([]grpc.UnaryServerInterceptor{TracingServerInterceptor()}, unary...)
+		stream = append([]grpc.StreamServerInterceptor{TracingStreamServerInterceptor()}, stream...)
+	}
+
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(chainUnaryServer(unary...)),
+		grpc.StreamInterceptor(chainStreamServer(stream...)),
+	}
+}
+
+// chainUnaryServer chains interceptors, the first being the outermost, as
+// a server only accepts one.
+func chainUnaryServer(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// chainStreamServer chains interceptors, the first being the outermost.
+func chainStreamServer(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, next)
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// panicResponse builds the response message for the RPC method carrying an
+// error diagnostic describing the panic.
+func panicResponse(ctx context.Context, fullMethod, typeName string, r interface{}) (interface{}, error) {
+	Logger(ctx).Error("plugin panicked", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+
+	// full methods look like /tfplugin5.Provider/ApplyResourceChange
+	rpc := path.Base(fullMethod)
+	service := path.Base(path.Dir(fullMethod))
+	pkg := strings.TrimSuffix(service, path.Ext(service))
+
+	detail := fmt.Sprintf("The plugin panicked while handling %s", rpc)
+	if typeName != "" {
+		detail += fmt.Sprintf(" for %s", typeName)
+	}
+	detail += fmt.Sprintf(": %v\n\nThis is always a bug in the plugin, please report it to the plugin developers.", r)
+
+	msgType := proto.MessageType(pkg + "." + rpc + ".Response")
+	if msgType == nil || msgType.Kind() != reflect.Ptr {
+		return nil, status.Errorf(codes.Internal, "%s: %s", rpc, detail)
+	}
+	resp := reflect.New(msgType.Elem())
+	elem := resp.Elem()
+	if f := elem.FieldByName("Diagnostics"); f.IsValid() && f.Type() == reflect.TypeOf([]*pb.Diagnostic{}) {
+		f.Set(reflect.ValueOf([]*pb.Diagnostic{
+			{
+				Severity: pb.Diagnostic_ERROR,
+				Summary:  fmt.Sprintf("Plugin panic in %s", rpc),
+				Detail:   detail,
+			},
+		}))
+		return resp.Interface(), nil
+	}
+	if f := elem.FieldByName("Diagnostics"); f.IsValid() && f.Type() == reflect.TypeOf([]*pb6.Diagnostic{}) {
+		f.Set(reflect.ValueOf([]*pb6.Diagnostic{
+			{
+				Severity: pb6.Diagnostic_ERROR,
+				Summary:  fmt.Sprintf("Plugin panic in %s", rpc),
+				Detail:   detail,
+			},
+		}))
+		return resp.Interface(), nil
+	}
+	if f := elem.FieldByName("Error"); f.IsValid() && f.Kind() == reflect.String {
+		f.SetString(detail)
+		return resp.Interface(), nil
 	}
+	return nil, status.Errorf(codes.Internal, "%s: %s", rpc, detail)
 }
@@ -0,0 +1,126 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+func TestRecoveryServerInterceptor(t *testing.T) {
+	interceptor := RecoveryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/tfplugin5.Provider/ApplyResourceChange"}
+
+	resp, err := interceptor(context.Background(), &pb.ApplyResourceChange_Request{TypeName: "test_thing"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("nil map")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applyResp, ok := resp.(*pb.ApplyResourceChange_Response)
+	if !ok {
+		t.Fatalf("unexpected response type %T", resp)
+	}
+	if len(applyResp.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", applyResp.Diagnostics)
+	}
+	d := applyResp.Diagnostics[0]
+	if d.Severity != pb.Diagnostic_ERROR {
+		t.Fatalf("expected error diagnostic, got %v", d.Severity)
+	}
+	for _, s := range []string{"ApplyResourceChange", "test_thing", "nil map"} {
+		if !strings.Contains(d.Detail, s) {
+			t.Fatalf("expected detail to contain %q, got %q", s, d.Detail)
+		}
+	}
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	sent []interface{}
+}
+
+func (s *testServerStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *testServerStream) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func TestRecoveryStreamServerInterceptor(t *testing.T) {
+	interceptor := RecoveryStreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/tfplugin5.Provisioner/ProvisionResource"}
+	ss := &testServerStream{}
+
+	err := interceptor(nil, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+		panic("nil map")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ss.sent) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(ss.sent))
+	}
+	resp, ok := ss.sent[0].(*pb.ProvisionResource_Response)
+	if !ok {
+		t.Fatalf("unexpected response type %T", ss.sent[0])
+	}
+	if len(resp.Diagnostics) != 1 || !strings.Contains(resp.Diagnostics[0].Detail, "nil map") {
+		t.Fatalf("unexpected diagnostics %v", resp.Diagnostics)
+	}
+}
+
+func TestLoggingServerInterceptorLogger(t *testing.T) {
+	var buf bytes.Buffer
+	rl := newRequestLogger(hclog.New(&hclog.LoggerOptions{
+		Level:      hclog.Trace,
+		Output:     &buf,
+		JSONFormat: true,
+	}))
+	noop := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+
+	_, err := rl.unary(context.Background(), &pb.Configure_Request{TerraformVersion: "0.12.0"}, &grpc.UnaryServerInfo{FullMethod: "/tfplugin5.Provider/Configure"}, noop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+
+	_, err = rl.unary(context.Background(), &pb.ReadResource_Request{TypeName: "test_thing"}, &grpc.UnaryServerInfo{FullMethod: "/tfplugin5.Provider/ReadResource"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		Logger(ctx).Debug("reading thing")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := buf.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	err = json.Unmarshal(line, &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry["@message"] != "reading thing" {
+		t.Fatalf("unexpected message %v", entry["@message"])
+	}
+	for k, v := range map[string]string{"rpc": "ReadResource", "type": "test_thing", "tf_version": "0.12.0"} {
+		if entry[k] != v {
+			t.Fatalf("expected %s to be %q, got %v", k, v, entry[k])
+		}
+	}
+	if entry["request_id"] == nil {
+		t.Fatal("expected a request_id")
+	}
+}
@@ -0,0 +1,293 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+	pb6 "github.com/hashicorp/terraform-plugin-sdk/tfplugin6"
+)
+
+const (
+	// EnvMetricsFile is the environment variable naming a file to which a
+	// summary of the RPCs served is written as JSON when the plugin is
+	// stopped or exits.
+	EnvMetricsFile = "TF_PLUGIN_METRICS_FILE"
+	// EnvMetricsAddr is the environment variable naming a local address,
+	// such as localhost:6060, on which the summary is served as the
+	// tfplugin_rpc expvar at /debug/vars.
+	EnvMetricsAddr = "TF_PLUGIN_METRICS_ADDR"
+)
+
+// Metrics collects the count, error diagnostics and latency of RPCs per
+// method and resource type.
+type Metrics struct {
+	mu    sync.Mutex
+	stats map[metricsKey]*rpcStats
+
+	// file, if set, is where dump writes the summary
+	file string
+}
+
+type metricsKey struct {
+	method   string
+	typeName string
+}
+
+const latencyBucketCount = 90
+
+// latencyBuckets are the upper bounds of the latency histogram buckets,
+// growing by a quarter power of two from 100µs to about 8 minutes, so
+// percentiles are estimated to within 19%.
+var latencyBuckets = func() []time.Duration {
+	buckets := make([]time.Duration, latencyBucketCount)
+	for i := range buckets {
+		buckets[i] = time.Duration(float64(100*time.Microsecond) * math.Pow(2, float64(i)/4))
+	}
+	return buckets
+}()
+
+type rpcStats struct {
+	count  int
+	errors int
+	// buckets counts the latencies per latencyBuckets bound, the last one
+	// counting those above all bounds
+	buckets  [latencyBucketCount + 1]int
+	min, max time.Duration
+}
+
+func (st *rpcStats) observe(elapsed time.Duration) {
+	i := sort.Search(len(latencyBuckets), func(i int) bool {
+		return elapsed <= latencyBuckets[i]
+	})
+	st.buckets[i]++
+	if st.count == 0 || elapsed < st.min {
+		st.min = elapsed
+	}
+	if elapsed > st.max {
+		st.max = elapsed
+	}
+	st.count++
+}
+
+// percentileMS estimates the percentile of the latencies in milliseconds as
+// the upper bound of the bucket holding its rank.
+func (st *rpcStats) percentileMS(p float64) float64 {
+	if st.count == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p * float64(st.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	estimate := st.max
+	seen := 0
+	for i, n := range st.buckets {
+		seen += n
+		if seen >= rank {
+			if i < len(latencyBuckets) && latencyBuckets[i] < estimate {
+				estimate = latencyBuckets[i]
+			}
+			break
+		}
+	}
+	if estimate < st.min {
+		estimate = st.min
+	}
+	return float64(estimate) / float64(time.Millisecond)
+}
+
+// MetricsSummary summarizes the RPCs of one method and resource type.
+type MetricsSummary struct {
+	Method   string  `json:"method"`
+	TypeName string  `json:"type_name,omitempty"`
+	Count    int     `json:"count"`
+	Errors   int     `json:"errors"`
+	P50MS    float64 `json:"p50_ms"`
+	P95MS    float64 `json:"p95_ms"`
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		stats: map[metricsKey]*rpcStats{},
+	}
+}
+
+var publishMetricsOnce sync.Once
+
+// metricsFromEnv returns the metrics enabled by EnvMetricsFile or
+// EnvMetricsAddr, or nil if neither is set.
+func metricsFromEnv() *Metrics {
+	file := os.Getenv(EnvMetricsFile)
+	addr := os.Getenv(EnvMetricsAddr)
+	if file == "" && addr == "" {
+		return nil
+	}
+
+	m := NewMetrics()
+	m.file = file
+	if addr != "" {
+		publishMetricsOnce.Do(func() {
+			expvar.Publish("tfplugin_rpc", expvar.Func(func() interface{} {
+				return m.Summary()
+			}))
+			go func() {
+				// expvar registers /debug/vars on the default mux
+				err := http.ListenAndServe(addr, nil)
+				if err != nil {
+					Logger(context.Background()).Error("unable to serve metrics", "addr", addr, "error", err)
+				}
+			}()
+		})
+	}
+	return m
+}
+
+func (m *Metrics) record(method, typeName string, elapsed time.Duration, errs int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := metricsKey{method: method, typeName: typeName}
+	st, ok := m.stats[key]
+	if !ok {
+		st = &rpcStats{}
+		m.stats[key] = st
+	}
+	st.observe(elapsed)
+	st.errors += errs
+}
+
+// Summary returns the summaries of all RPCs recorded, ordered by method and
+// resource type.
+func (m *Metrics) Summary() []MetricsSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	summaries := make([]MetricsSummary, 0, len(m.stats))
+	for key, st := range m.stats {
+		summaries = append(summaries, MetricsSummary{
+			Method:   key.method,
+			TypeName: key.typeName,
+			Count:    st.count,
+			Errors:   st.errors,
+			P50MS:    st.percentileMS(0.50),
+			P95MS:    st.percentileMS(0.95),
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Method != summaries[j].Method {
+			return summaries[i].Method < summaries[j].Method
+		}
+		return summaries[i].TypeName < summaries[j].TypeName
+	})
+	return summaries
+}
+
+// WriteJSON writes the summary as JSON.
+func (m *Metrics) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.WithStack(enc.Encode(m.Summary()))
+}
+
+// dump writes the summary to the metrics file, if set.
+func (m *Metrics) dump() {
+	if m == nil || m.file == "" {
+		return
+	}
+	f, err := os.Create(m.file)
+	if err != nil {
+		Logger(context.Background()).Error("unable to write metrics", "path", m.file, "error", err)
+		return
+	}
+	defer f.Close()
+	err = m.WriteJSON(f)
+	if err != nil {
+		Logger(context.Background()).Error("unable to write metrics", "path", m.file, "error", err)
+	}
+}
+
+// UnaryServerInterceptor records every unary RPC, writing the summary when
+// the plugin is stopped.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		typeName := ""
+		if tn, ok := req.(interface{ GetTypeName() string }); ok {
+			typeName = tn.GetTypeName()
+		}
+
+		clock := clockFromContext(ctx)
+		start := clock.Now()
+		resp, err := handler(ctx, req)
+		errs := errorDiagnosticsCount(resp)
+		if err != nil {
+			errs++
+		}
+		method := path.Base(info.FullMethod)
+		m.record(method, typeName, clock.Now().Sub(start), errs)
+
+		if method == "Stop" || method == "StopProvider" {
+			m.dump()
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records every streaming RPC.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ms := &metricsServerStream{ServerStream: ss}
+
+		clock := clockFromContext(ss.Context())
+		start := clock.Now()
+		err := handler(srv, ms)
+		if err != nil {
+			ms.errors++
+		}
+		m.record(path.Base(info.FullMethod), "", clock.Now().Sub(start), ms.errors)
+		return err
+	}
+}
+
+// metricsServerStream counts the error diagnostics sent.
+type metricsServerStream struct {
+	grpc.ServerStream
+	errors int
+}
+
+func (s *metricsServerStream) SendMsg(msg interface{}) error {
+	s.errors += errorDiagnosticsCount(msg)
+	return s.ServerStream.SendMsg(msg)
+}
+
+func errorDiagnosticsCount(resp interface{}) int {
+	count := 0
+	switch d := resp.(type) {
+	case interface{ GetDiagnostics() []*pb.Diagnostic }:
+		for _, diag := range d.GetDiagnostics() {
+			if diag.Severity == pb.Diagnostic_ERROR {
+				count++
+			}
+		}
+	case interface{ GetDiagnostics() []*pb6.Diagnostic }:
+		for _, diag := range d.GetDiagnostics() {
+			if diag.Severity == pb6.Diagnostic_ERROR {
+				count++
+			}
+		}
+	}
+	return count
+}
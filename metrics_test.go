@@ -0,0 +1,74 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+func TestMetricsUnaryServerInterceptor(t *testing.T) {
+	m := NewMetrics()
+	m.file = filepath.Join(t.TempDir(), "metrics.json")
+	interceptor := m.UnaryServerInterceptor()
+	clock := newFakeClock()
+	ctx := WithClock(context.Background(), clock)
+
+	apply := &grpc.UnaryServerInfo{FullMethod: "/tfplugin5.Provider/ApplyResourceChange"}
+	for i := 1; i <= 20; i++ {
+		delay := time.Duration(i) * time.Millisecond
+		_, err := interceptor(ctx, &pb.ApplyResourceChange_Request{TypeName: "test_thing"}, apply, func(ctx context.Context, req interface{}) (interface{}, error) {
+			resp := &pb.ApplyResourceChange_Response{}
+			if delay == 20*time.Millisecond {
+				resp.Diagnostics = []*pb.Diagnostic{{Severity: pb.Diagnostic_ERROR, Summary: "failed"}}
+			}
+			<-clock.After(delay)
+			return resp, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stop := &grpc.UnaryServerInfo{FullMethod: "/tfplugin5.Provider/Stop"}
+	_, err := interceptor(ctx, &pb.Stop_Request{}, stop, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &pb.Stop_Response{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(m.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summaries []MetricsSummary
+	err = json.Unmarshal(data, &summaries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 summaries, got %#v", summaries)
+	}
+
+	s := summaries[0]
+	if s.Method != "ApplyResourceChange" || s.TypeName != "test_thing" || s.Count != 20 || s.Errors != 1 {
+		t.Fatalf("unexpected summary %#v", s)
+	}
+	// estimated from the histogram, capped at the slowest call
+	if s.P50MS < 10 || s.P50MS > 10*1.19 {
+		t.Fatalf("unexpected p50 %vms", s.P50MS)
+	}
+	if s.P95MS < 19 || s.P95MS > 20 {
+		t.Fatalf("unexpected p95 %vms", s.P95MS)
+	}
+	if summaries[1].Method != "Stop" {
+		t.Fatalf("unexpected summary %#v", summaries[1])
+	}
+}
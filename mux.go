@@ -0,0 +1,275 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/gogo/protobuf/proto"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+// MuxServer serves several providers as one, for example while migrating
+// resources from one implementation to another. Each resource and data
+// source type must be implemented by exactly one of the providers, which
+// all share the same provider schema. RPCs for a type are routed to its
+// provider, and Configure and Stop are sent to all of them.
+type MuxServer struct {
+	servers []*GRPCProviderServer
+
+	schema      *pb.GetProviderSchema_Response
+	resources   map[string]*GRPCProviderServer
+	dataSources map[string]*GRPCProviderServer
+}
+
+// NewMuxServer returns a server of providers, or an error describing all
+// type names implemented by more than one of them and any provider schema
+// which differs from that of the first provider.
+func NewMuxServer(providers ...Provider) (*MuxServer, error) {
+	conf, err := newServeConfig(nil, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return newMuxServer(providers, conf)
+}
+
+func newMuxServer(providers []Provider, conf *serveConfig) (*MuxServer, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("no providers to serve")
+	}
+
+	m := &MuxServer{
+		schema: &pb.GetProviderSchema_Response{
+			ResourceSchemas:   map[string]*pb.Schema{},
+			DataSourceSchemas: map[string]*pb.Schema{},
+		},
+		resources:   map[string]*GRPCProviderServer{},
+		dataSources: map[string]*GRPCProviderServer{},
+	}
+
+	// owners are the indexes of the providers implementing each type
+	resourceOwners := map[string][]int{}
+	dataSourceOwners := map[string][]int{}
+	var errs error
+	for i, p := range providers {
+		s := newGRPCProviderServer(p, conf)
+		m.servers = append(m.servers, s)
+
+		resp, err := s.GetSchema(context.Background(), &pb.GetProviderSchema_Request{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get schema of %s", muxProviderName(providers, i))
+		}
+
+		if i == 0 {
+			m.schema.Provider = resp.Provider
+		} else if !proto.Equal(resp.Provider, m.schema.Provider) {
+			errs = multierror.Append(errs, errors.Errorf("provider schema of %s differs from that of %s", muxProviderName(providers, i), muxProviderName(providers, 0)))
+		}
+		for n, schema := range resp.ResourceSchemas {
+			resourceOwners[n] = append(resourceOwners[n], i)
+			m.schema.ResourceSchemas[n] = schema
+			m.resources[n] = s
+		}
+		for n, schema := range resp.DataSourceSchemas {
+			dataSourceOwners[n] = append(dataSourceOwners[n], i)
+			m.schema.DataSourceSchemas[n] = schema
+			m.dataSources[n] = s
+		}
+	}
+	errs = duplicateTypeErrors(errs, providers, "resource", resourceOwners)
+	errs = duplicateTypeErrors(errs, providers, "data source", dataSourceOwners)
+	if errs != nil {
+		return nil, errors.WithStack(errs)
+	}
+
+	return m, nil
+}
+
+// duplicateTypeErrors appends an error for every type name with more than
+// one owner, in name order.
+func duplicateTypeErrors(errs error, providers []Provider, kind string, owners map[string][]int) error {
+	var names []string
+	for n, o := range owners {
+		if len(o) > 1 {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		var impls []string
+		for _, i := range owners[n] {
+			impls = append(impls, muxProviderName(providers, i))
+		}
+		errs = multierror.Append(errs, errors.Errorf("%s type %s is implemented by more than one provider: %v", kind, n, impls))
+	}
+	return errs
+}
+
+// muxProviderName describes the provider at index i by its position and Go
+// type, as providers have no names of their own.
+func muxProviderName(providers []Provider, i int) string {
+	return fmt.Sprintf("provider %d (%T)", i, providers[i])
+}
+
+func (m *MuxServer) resource(typeName string) (*GRPCProviderServer, error) {
+	s, ok := m.resources[typeName]
+	if !ok {
+		return nil, errors.Errorf("unknown resource type: %s", typeName)
+	}
+	return s, nil
+}
+
+func (m *MuxServer) dataSource(typeName string) (*GRPCProviderServer, error) {
+	s, ok := m.dataSources[typeName]
+	if !ok {
+		return nil, errors.Errorf("unknown data source type: %s", typeName)
+	}
+	return s, nil
+}
+
+func (m *MuxServer) GetSchema(ctx context.Context, req *pb.GetProviderSchema_Request) (*pb.GetProviderSchema_Response, error) {
+	return m.schema, nil
+}
+
+// PrepareProviderConfig prepares the configuration with every provider,
+// returning the configuration prepared by the first.
+func (m *MuxServer) PrepareProviderConfig(ctx context.Context, req *pb.PrepareProviderConfig_Request) (*pb.PrepareProviderConfig_Response, error) {
+	var resp *pb.PrepareProviderConfig_Response
+	var diags []*pb.Diagnostic
+	for _, s := range m.servers {
+		r, err := s.PrepareProviderConfig(ctx, req)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if resp == nil {
+			resp = r
+		}
+		diags = appendUniqueDiagnostics(diags, r.Diagnostics...)
+	}
+	return &pb.PrepareProviderConfig_Response{
+		PreparedConfig: resp.PreparedConfig,
+		Diagnostics:    diags,
+	}, nil
+}
+
+func (m *MuxServer) ValidateResourceTypeConfig(ctx context.Context, req *pb.ValidateResourceTypeConfig_Request) (*pb.ValidateResourceTypeConfig_Response, error) {
+	s, err := m.resource(req.TypeName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s.ValidateResourceTypeConfig(ctx, req)
+}
+
+func (m *MuxServer) ValidateDataSourceConfig(ctx context.Context, req *pb.ValidateDataSourceConfig_Request) (*pb.ValidateDataSourceConfig_Response, error) {
+	s, err := m.dataSource(req.TypeName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s.ValidateDataSourceConfig(ctx, req)
+}
+
+func (m *MuxServer) UpgradeResourceState(ctx context.Context, req *pb.UpgradeResourceState_Request) (*pb.UpgradeResourceState_Response, error) {
+	s, err := m.resource(req.TypeName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s.UpgradeResourceState(ctx, req)
+}
+
+// Configure configures every provider, returning the diagnostics of all of
+// them.
+func (m *MuxServer) Configure(ctx context.Context, req *pb.Configure_Request) (*pb.Configure_Response, error) {
+	var diags []*pb.Diagnostic
+	for _, s := range m.servers {
+		r, err := s.Configure(ctx, req)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		diags = appendUniqueDiagnostics(diags, r.Diagnostics...)
+	}
+	return &pb.Configure_Response{
+		Diagnostics: diags,
+	}, nil
+}
+
+func (m *MuxServer) ReadResource(ctx context.Context, req *pb.ReadResource_Request) (*pb.ReadResource_Response, error) {
+	s, err := m.resource(req.TypeName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s.ReadResource(ctx, req)
+}
+
+func (m *MuxServer) PlanResourceChange(ctx context.Context, req *pb.PlanResourceChange_Request) (*pb.PlanResourceChange_Response, error) {
+	s, err := m.resource(req.TypeName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s.PlanResourceChange(ctx, req)
+}
+
+func (m *MuxServer) ApplyResourceChange(ctx context.Context, req *pb.ApplyResourceChange_Request) (*pb.ApplyResourceChange_Response, error) {
+	s, err := m.resource(req.TypeName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s.ApplyResourceChange(ctx, req)
+}
+
+func (m *MuxServer) ImportResourceState(ctx context.Context, req *pb.ImportResourceState_Request) (*pb.ImportResourceState_Response, error) {
+	s, err := m.resource(req.TypeName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s.ImportResourceState(ctx, req)
+}
+
+func (m *MuxServer) ReadDataSource(ctx context.Context, req *pb.ReadDataSource_Request) (*pb.ReadDataSource_Response, error) {
+	s, err := m.dataSource(req.TypeName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s.ReadDataSource(ctx, req)
+}
+
+// Stop stops every provider concurrently, as each waits for its in-flight
+// operations, and returns the errors of all of them.
+func (m *MuxServer) Stop(ctx context.Context, req *pb.Stop_Request) (*pb.Stop_Response, error) {
+	errCh := make(chan error, len(m.servers))
+	for _, s := range m.servers {
+		go func(s *GRPCProviderServer) {
+			_, err := s.Stop(ctx, req)
+			errCh <- err
+		}(s)
+	}
+
+	var errs error
+	for range m.servers {
+		if err := <-errCh; err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	if errs != nil {
+		return nil, errors.WithStack(errs)
+	}
+	return &pb.Stop_Response{}, nil
+}
+
+// appendUniqueDiagnostics appends the diagnostics which are not in diags
+// yet, so the providers sharing a schema do not repeat each other.
+func appendUniqueDiagnostics(diags []*pb.Diagnostic, more ...*pb.Diagnostic) []*pb.Diagnostic {
+next:
+	for _, d := range more {
+		for _, existing := range diags {
+			if proto.Equal(d, existing) {
+				continue next
+			}
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
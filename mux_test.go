@@ -0,0 +1,133 @@
+package sdk
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+// testMuxProvider records Configure calls and can override the provider
+// schema.
+type testMuxProvider struct {
+	*testProvider
+	schema     *Schema
+	tfVersions []string
+}
+
+func (p *testMuxProvider) Configure(ctx context.Context, tfVersion string) error {
+	p.tfVersions = append(p.tfVersions, tfVersion)
+	return nil
+}
+
+func (p *testMuxProvider) Schema() Schema {
+	if p.schema != nil {
+		return *p.schema
+	}
+	return p.testProvider.Schema()
+}
+
+func newTestMuxProvider(typeNames ...string) *testMuxProvider {
+	p := &testMuxProvider{testProvider: &testProvider{resources: map[string]func() Resource{}}}
+	for _, n := range typeNames {
+		p.resources[n] = func() Resource { return &testResource{} }
+	}
+	return p
+}
+
+func TestMuxServer(t *testing.T) {
+	ctx := context.Background()
+	old := newTestMuxProvider("test_thing")
+	created := false
+	next := newTestMuxProvider()
+	next.resources["test_other"] = func() Resource {
+		return &testResource{create: func(context.Context, *testResource) error {
+			created = true
+			return nil
+		}}
+	}
+
+	m, err := NewMuxServer(old, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := m.GetSchema(ctx, &pb.GetProviderSchema_Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []string{"test_thing", "test_other"} {
+		if _, ok := schema.ResourceSchemas[n]; !ok {
+			t.Fatalf("expected %s schema, got %v", n, schema.ResourceSchemas)
+		}
+	}
+
+	configure, err := m.Configure(ctx, &pb.Configure_Request{
+		TerraformVersion: "0.12.0",
+		Config: pbDynamicValue(testMsgpack(t, cty.ObjectVal(map[string]cty.Value{
+			"endpoint": cty.NullVal(cty.String),
+		}))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configure.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics %v", configure.Diagnostics)
+	}
+	if len(old.tfVersions) != 1 || len(next.tfVersions) != 1 {
+		t.Fatalf("expected both providers to be configured once, got %v and %v", old.tfVersions, next.tfVersions)
+	}
+
+	apply, err := m.ApplyResourceChange(ctx, &pb.ApplyResourceChange_Request{
+		TypeName:     "test_other",
+		PriorState:   pbDynamicValue(testMsgpack(t, cty.NullVal(testThingVal("", "", nil).Type()))),
+		PlannedState: pbDynamicValue(testMsgpack(t, testThingVal("", "foo", nil))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apply.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics %v", apply.Diagnostics)
+	}
+	if !created {
+		t.Fatal("expected test_other to be created by its provider")
+	}
+
+	_, err = m.ReadResource(ctx, &pb.ReadResource_Request{TypeName: "test_nothing"})
+	if err == nil || !strings.Contains(err.Error(), "unknown resource type: test_nothing") {
+		t.Fatalf("expected unknown resource type error, got %v", err)
+	}
+
+	_, err = m.Stop(ctx, &pb.Stop_Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewMuxServerConflicts(t *testing.T) {
+	differing := newTestMuxProvider("test_other")
+	differing.schema = &Schema{
+		Block: Block{
+			Attributes: []Attribute{
+				{Name: "region", Type: cty.String, Required: true},
+			},
+		},
+	}
+
+	_, err := NewMuxServer(newTestMuxProvider("test_thing", "test_a"), newTestMuxProvider("test_thing", "test_a"), differing)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, expected := range []string{
+		"resource type test_a is implemented by more than one provider: [provider 0 (*sdk.testMuxProvider) provider 1 (*sdk.testMuxProvider)]",
+		"resource type test_thing is implemented by more than one provider",
+		"provider schema of provider 2 (*sdk.testMuxProvider) differs from that of provider 0 (*sdk.testMuxProvider)",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error to contain %q, got:\n%s", expected, err)
+		}
+	}
+}
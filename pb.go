@@ -8,23 +8,34 @@ import (
 	"github.com/zclconf/go-cty/cty"
 )
 
-func pbDynamicValue(v []byte) *pb.DynamicValue {
+func dynamicValue(v *pb.DynamicValue) DynamicValue {
 	if v == nil {
+		return DynamicValue{}
+	}
+	return DynamicValue{
+		MsgPack: v.Msgpack,
+		JSON:    v.Json,
+	}
+}
+
+func pbDynamicValue(v DynamicValue) *pb.DynamicValue {
+	if v.IsEmpty() {
 		return nil
 	}
 	return &pb.DynamicValue{
-		Msgpack: v,
+		Msgpack: v.MsgPack,
+		Json:    v.JSON,
 	}
 }
 
 func pbSchemaAttribute(v Attribute) (*pb.Schema_Attribute, error) {
-	jsonType, err := json.Marshal(v.Type)
+	jsonType, err := json.Marshal(v.ImpliedType())
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to marshal attribute type: %s", v.Name)
 	}
 
 	computed := v.Computed
-	if v.Optional && v.Type.IsPrimitiveType() {
+	if v.Optional && v.ImpliedType().IsPrimitiveType() {
 		// marking optional things as computed here to
 		// allow for local defaulting (env vars, static defaults)
 		// TODO: figure out how to find just the ones that do default?
@@ -52,9 +63,47 @@ func pbSchemaBlock(v Block) (*pb.Schema_Block, error) {
 		}
 	}
 
+	blockTypes := make([]*pb.Schema_NestedBlock, len(v.BlockTypes))
+	for i, nb := range v.BlockTypes {
+		blockTypes[i], err = pbSchemaNestedBlock(nb)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
 	return &pb.Schema_Block{
 		Version:    int64(v.Version),
 		Attributes: atts,
+		BlockTypes: blockTypes,
+	}, nil
+}
+
+func pbSchemaNestedBlock(v NestedBlock) (*pb.Schema_NestedBlock, error) {
+	block, err := pbSchemaBlock(v.Block)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to convert nested block: %s", v.TypeName)
+	}
+
+	var nesting pb.Schema_NestedBlock_NestingMode
+	switch v.Nesting {
+	case NestingSingle:
+		nesting = pb.Schema_NestedBlock_SINGLE
+	case NestingList:
+		nesting = pb.Schema_NestedBlock_LIST
+	case NestingSet:
+		nesting = pb.Schema_NestedBlock_SET
+	case NestingMap:
+		nesting = pb.Schema_NestedBlock_MAP
+	default:
+		return nil, errors.Errorf("unexpected nesting mode %d for nested block: %s", v.Nesting, v.TypeName)
+	}
+
+	return &pb.Schema_NestedBlock{
+		TypeName: v.TypeName,
+		Block:    block,
+		Nesting:  nesting,
+		MinItems: int64(v.MinItems),
+		MaxItems: int64(v.MaxItems),
 	}, nil
 }
 
@@ -182,3 +231,151 @@ func pbDiagnostics(v []Diagnostic) ([]*pb.Diagnostic, error) {
 	}
 	return diags, nil
 }
+
+func schemaFromPB(v *pb.Schema) (Schema, error) {
+	if v == nil {
+		return Schema{}, nil
+	}
+	block, err := blockFromPB(v.Block)
+	if err != nil {
+		return Schema{}, errors.WithStack(err)
+	}
+	return Schema{
+		Version: int(v.Version),
+		Block:   block,
+	}, nil
+}
+
+func mapSchemaFromPB(v map[string]*pb.Schema) (map[string]Schema, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	m := make(map[string]Schema, len(v))
+	var err error
+	for k, s := range v {
+		m[k], err = schemaFromPB(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert schema: %s", k)
+		}
+	}
+	return m, nil
+}
+
+func blockFromPB(v *pb.Schema_Block) (Block, error) {
+	if v == nil {
+		return Block{}, nil
+	}
+
+	atts := make(Attributes, len(v.Attributes))
+	for i, att := range v.Attributes {
+		var ty cty.Type
+		err := json.Unmarshal(att.Type, &ty)
+		if err != nil {
+			return Block{}, errors.Wrapf(err, "unable to unmarshal attribute type: %s", att.Name)
+		}
+		atts[i] = Attribute{
+			Name:        att.Name,
+			Description: att.Description,
+			Type:        ty,
+			Required:    att.Required,
+			Optional:    att.Optional,
+			Computed:    att.Computed,
+			Sensitive:   att.Sensitive,
+		}
+	}
+
+	blockTypes := make([]NestedBlock, len(v.BlockTypes))
+	for i, nb := range v.BlockTypes {
+		block, err := blockFromPB(nb.Block)
+		if err != nil {
+			return Block{}, errors.Wrapf(err, "unable to convert nested block: %s", nb.TypeName)
+		}
+
+		var nesting NestingMode
+		switch nb.Nesting {
+		case pb.Schema_NestedBlock_SINGLE:
+			nesting = NestingSingle
+		case pb.Schema_NestedBlock_LIST:
+			nesting = NestingList
+		case pb.Schema_NestedBlock_SET:
+			nesting = NestingSet
+		case pb.Schema_NestedBlock_MAP:
+			nesting = NestingMap
+		default:
+			return Block{}, errors.Errorf("unexpected nesting mode %s for nested block: %s", nb.Nesting, nb.TypeName)
+		}
+
+		blockTypes[i] = NestedBlock{
+			TypeName: nb.TypeName,
+			Nesting:  nesting,
+			Block:    block,
+			MinItems: int(nb.MinItems),
+			MaxItems: int(nb.MaxItems),
+		}
+	}
+
+	return Block{
+		Version:    int(v.Version),
+		Attributes: atts,
+		BlockTypes: blockTypes,
+	}, nil
+}
+
+func attributePathFromPB(v *pb.AttributePath) (cty.Path, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	var path cty.Path
+	for _, s := range v.Steps {
+		switch s := s.Selector.(type) {
+		case *pb.AttributePath_Step_AttributeName:
+			path = path.GetAttr(s.AttributeName)
+		case *pb.AttributePath_Step_ElementKeyString:
+			path = path.Index(cty.StringVal(s.ElementKeyString))
+		case *pb.AttributePath_Step_ElementKeyInt:
+			path = path.Index(cty.NumberIntVal(s.ElementKeyInt))
+		default:
+			return nil, errors.Errorf("unexpected attribute path step: %T", s)
+		}
+	}
+	return path, nil
+}
+
+func attributePathsFromPB(v []*pb.AttributePath) ([]cty.Path, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	paths := make([]cty.Path, len(v))
+	var err error
+	for i, p := range v {
+		paths[i], err = attributePathFromPB(p)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return paths, nil
+}
+
+func diagnosticsFromPB(v []*pb.Diagnostic) (Diagnostics, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	diags := make(Diagnostics, len(v))
+	for i, d := range v {
+		path, err := attributePathFromPB(d.Attribute)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		diags[i] = Diagnostic{
+			Path:     path,
+			Severity: Severity(d.Severity),
+			Summary:  d.Summary,
+			Detail:   d.Detail,
+		}
+	}
+	return diags, nil
+}
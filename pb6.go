@@ -0,0 +1,253 @@
+package sdk // import "github.com/hashicorp/terraform-plugin-sdk"
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+	pb6 "github.com/hashicorp/terraform-plugin-sdk/tfplugin6"
+)
+
+func dynamicValue6(v *pb6.DynamicValue) DynamicValue {
+	if v == nil {
+		return DynamicValue{}
+	}
+	return DynamicValue{
+		MsgPack: v.Msgpack,
+		JSON:    v.Json,
+	}
+}
+
+func pb6DynamicValue(v DynamicValue) *pb6.DynamicValue {
+	if v.IsEmpty() {
+		return nil
+	}
+	return &pb6.DynamicValue{
+		Msgpack: v.MsgPack,
+		Json:    v.JSON,
+	}
+}
+
+func pb6SchemaAttribute(v Attribute) (*pb6.Schema_Attribute, error) {
+	att := &pb6.Schema_Attribute{
+		Name:        v.Name,
+		Description: v.Description,
+		Required:    v.Required,
+		Optional:    v.Optional,
+		Computed:    v.Computed,
+		Sensitive:   v.Sensitive,
+	}
+
+	if v.NestedType != nil {
+		nestedType, err := pb6SchemaObject(*v.NestedType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert nested attributes: %s", v.Name)
+		}
+		att.NestedType = nestedType
+		return att, nil
+	}
+
+	jsonType, err := json.Marshal(v.Type)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to marshal attribute type: %s", v.Name)
+	}
+	att.Type = jsonType
+
+	if v.Optional && v.Type.IsPrimitiveType() {
+		// see pbSchemaAttribute
+		att.Computed = true
+	}
+
+	return att, nil
+}
+
+func pb6SchemaAttributes(v Attributes) ([]*pb6.Schema_Attribute, error) {
+	atts := make([]*pb6.Schema_Attribute, len(v))
+	var err error
+	for i, att := range v {
+		atts[i], err = pb6SchemaAttribute(att)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return atts, nil
+}
+
+func pb6SchemaObject(v Object) (*pb6.Schema_Object, error) {
+	atts, err := pb6SchemaAttributes(v.Attributes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var nesting pb6.Schema_Object_NestingMode
+	switch v.Nesting {
+	case NestingSingle:
+		nesting = pb6.Schema_Object_SINGLE
+	case NestingList:
+		nesting = pb6.Schema_Object_LIST
+	case NestingSet:
+		nesting = pb6.Schema_Object_SET
+	case NestingMap:
+		nesting = pb6.Schema_Object_MAP
+	default:
+		return nil, errors.Errorf("unexpected nesting mode %d for nested attributes", v.Nesting)
+	}
+
+	return &pb6.Schema_Object{
+		Attributes: atts,
+		Nesting:    nesting,
+		MinItems:   int64(v.MinItems),
+		MaxItems:   int64(v.MaxItems),
+	}, nil
+}
+
+func pb6SchemaBlock(v Block) (*pb6.Schema_Block, error) {
+	atts, err := pb6SchemaAttributes(v.Attributes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	blockTypes := make([]*pb6.Schema_NestedBlock, len(v.BlockTypes))
+	for i, nb := range v.BlockTypes {
+		blockTypes[i], err = pb6SchemaNestedBlock(nb)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return &pb6.Schema_Block{
+		Version:    int64(v.Version),
+		Attributes: atts,
+		BlockTypes: blockTypes,
+	}, nil
+}
+
+func pb6SchemaNestedBlock(v NestedBlock) (*pb6.Schema_NestedBlock, error) {
+	block, err := pb6SchemaBlock(v.Block)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to convert nested block: %s", v.TypeName)
+	}
+
+	var nesting pb6.Schema_NestedBlock_NestingMode
+	switch v.Nesting {
+	case NestingSingle:
+		nesting = pb6.Schema_NestedBlock_SINGLE
+	case NestingList:
+		nesting = pb6.Schema_NestedBlock_LIST
+	case NestingSet:
+		nesting = pb6.Schema_NestedBlock_SET
+	case NestingMap:
+		nesting = pb6.Schema_NestedBlock_MAP
+	default:
+		return nil, errors.Errorf("unexpected nesting mode %d for nested block: %s", v.Nesting, v.TypeName)
+	}
+
+	return &pb6.Schema_NestedBlock{
+		TypeName: v.TypeName,
+		Block:    block,
+		Nesting:  nesting,
+		MinItems: int64(v.MinItems),
+		MaxItems: int64(v.MaxItems),
+	}, nil
+}
+
+func pb6Schema(v Schema) (*pb6.Schema, error) {
+	block, err := pb6SchemaBlock(v.Block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &pb6.Schema{
+		Version: int64(v.Version),
+		Block:   block,
+	}, nil
+}
+
+func pb6MapSchema(v map[string]Schema) (map[string]*pb6.Schema, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	m := make(map[string]*pb6.Schema, len(v))
+	var err error
+	for k, s := range v {
+		m[k], err = pb6Schema(s)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return m, nil
+}
+
+func pb6AttributePathSteps(v []cty.PathStep) ([]*pb6.AttributePath_Step, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	steps := make([]*pb6.AttributePath_Step, len(v))
+	for i, s := range v {
+		step, err := pbAttributePathStep(s)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		// the steps are the same in both protocol versions
+		switch sel := step.Selector.(type) {
+		case *pb.AttributePath_Step_AttributeName:
+			steps[i] = &pb6.AttributePath_Step{
+				Selector: &pb6.AttributePath_Step_AttributeName{AttributeName: sel.AttributeName},
+			}
+		case *pb.AttributePath_Step_ElementKeyString:
+			steps[i] = &pb6.AttributePath_Step{
+				Selector: &pb6.AttributePath_Step_ElementKeyString{ElementKeyString: sel.ElementKeyString},
+			}
+		case *pb.AttributePath_Step_ElementKeyInt:
+			steps[i] = &pb6.AttributePath_Step{
+				Selector: &pb6.AttributePath_Step_ElementKeyInt{ElementKeyInt: sel.ElementKeyInt},
+			}
+		}
+	}
+	return steps, nil
+}
+
+func pb6AttributePaths(v []cty.Path) ([]*pb6.AttributePath, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	paths := make([]*pb6.AttributePath, len(v))
+	for i, p := range v {
+		steps, err := pb6AttributePathSteps(p)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		paths[i] = &pb6.AttributePath{
+			Steps: steps,
+		}
+	}
+	return paths, nil
+}
+
+func pb6Diagnostics(v []Diagnostic) ([]*pb6.Diagnostic, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	diags := make([]*pb6.Diagnostic, len(v))
+	for i, d := range v {
+		steps, err := pb6AttributePathSteps(d.Path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		diags[i] = &pb6.Diagnostic{
+			Detail:   d.Detail,
+			Summary:  d.Summary,
+			Severity: pb6.Diagnostic_Severity(d.Severity),
+			Attribute: &pb6.AttributePath{
+				Steps: steps,
+			},
+		}
+	}
+	return diags, nil
+}
@@ -484,7 +484,7 @@ func testProviderResolver(c TestCase) (providers.Resolver, error) {
 
 func grpcTestProvider(p sdk.Provider) (providers.Interface, error) {
 	listener := bufconn.Listen(256 * 1024)
-	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(sdk.LoggingServerInterceptor()))
+	grpcServer := grpc.NewServer(sdk.ServerOptions()...)
 
 	grpcProvider := &sdk.GRPCProviderServer{
 		Server: sdk.Server{
@@ -9,8 +9,6 @@ import (
 
 type Provider interface {
 	Configure(context.Context, string) error
-	// TODO: make this optional, cast to see if it exists?
-	Stop(context.Context) error
 
 	// generated methods
 	Schema() Schema
@@ -42,6 +40,13 @@ type Resource interface {
 	MarshalState() (cty.Value, error)
 }
 
+// Stopper can optionally be implemented by a provider to be notified when
+// Terraform stops it. The contexts of in-flight operations are cancelled
+// before Stop is called.
+type Stopper interface {
+	Stop(context.Context) error
+}
+
 type Defaulter interface {
 	SetDefaults()
 }
@@ -50,6 +55,20 @@ type Updater interface {
 	Update(context.Context) error
 }
 
+// Importer is implemented by resources which can be imported. Import sets
+// the fields identifying the remote object from the ID given to terraform
+// import, Terraform then reads the resource to fill in the rest.
+type Importer interface {
+	Import(ctx context.Context, id string) error
+}
+
+// StateUpgrader is implemented by resources whose Schema Version was
+// increased. UpgradeState converts rawState, the JSON state written with
+// the older schema version, to a value of the current schema.
+type StateUpgrader interface {
+	UpgradeState(ctx context.Context, version int, rawState []byte) (cty.Value, error)
+}
+
 type Validator interface {
 	Validate() error
 }
@@ -62,9 +81,12 @@ type Schema struct {
 type Block struct {
 	Version    int
 	Attributes Attributes
-	// TODO: Blocks []NestedBlock
+	BlockTypes []NestedBlock
 }
 
+// ApplyPath returns the attribute for the path. Paths to a nested block
+// resolve to an optional attribute of the block's type, as blocks are
+// always supplied in configuration.
 func (b Block) ApplyPath(path cty.Path) (*Attribute, error) {
 	if len(path) < 1 {
 		return nil, fmt.Errorf("path length must be at least 1")
@@ -75,17 +97,90 @@ func (b Block) ApplyPath(path cty.Path) (*Attribute, error) {
 	if !ok {
 		panic("bad first path step")
 	}
-	return b.Attributes.Lookup(get.Name), nil
+	if att := b.Attributes.Lookup(get.Name); att != nil {
+		rest := path[1:]
+		if att.NestedType != nil && att.NestedType.Nesting != NestingSingle && len(rest) > 0 {
+			// skip the element step
+			rest = rest[1:]
+		}
+		if att.NestedType == nil || len(rest) == 0 {
+			return att, nil
+		}
+		return Block{Attributes: att.NestedType.Attributes}.ApplyPath(rest)
+	}
+
+	nb := b.lookupBlockType(get.Name)
+	if nb == nil {
+		return nil, nil
+	}
+	rest := path[1:]
+	if nb.Nesting != NestingSingle && len(rest) > 0 {
+		// skip the element step
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return &Attribute{
+			Name:     nb.TypeName,
+			Type:     nb.ImpliedType(),
+			Optional: true,
+		}, nil
+	}
+	return nb.Block.ApplyPath(rest)
 }
 
-func (b Block) impliedType() cty.Type {
+func (b Block) lookupBlockType(name string) *NestedBlock {
+	for _, nb := range b.BlockTypes {
+		if nb.TypeName == name {
+			return &nb
+		}
+	}
+	return nil
+}
+
+// ImpliedType returns the object type of values of the block.
+func (b Block) ImpliedType() cty.Type {
 	atts := map[string]cty.Type{}
 	for _, att := range b.Attributes {
-		atts[att.Name] = att.Type
+		atts[att.Name] = att.ImpliedType()
+	}
+	for _, nb := range b.BlockTypes {
+		atts[nb.TypeName] = nb.ImpliedType()
 	}
 	return cty.Object(atts)
 }
 
+type NestingMode int
+
+const (
+	NestingSingle NestingMode = iota
+	NestingList
+	NestingSet
+	NestingMap
+)
+
+type NestedBlock struct {
+	TypeName string
+	Nesting  NestingMode
+	Block    Block
+
+	MinItems int
+	MaxItems int
+}
+
+// ImpliedType returns the type of values of the nested block.
+func (nb NestedBlock) ImpliedType() cty.Type {
+	ty := nb.Block.ImpliedType()
+	switch nb.Nesting {
+	case NestingList:
+		return cty.List(ty)
+	case NestingSet:
+		return cty.Set(ty)
+	case NestingMap:
+		return cty.Map(ty)
+	}
+	return ty
+}
+
 type Attributes []Attribute
 
 func (atts Attributes) Lookup(name string) *Attribute {
@@ -103,6 +198,11 @@ type Attribute struct {
 
 	Type cty.Type
 
+	// NestedType, if set, declares nested attributes in place of Type.
+	// Protocol 5 has no nested attributes, so they are exposed to older
+	// versions of Terraform as a value of their implied type.
+	NestedType *Object
+
 	Required bool
 	Optional bool
 	Computed bool
@@ -112,6 +212,42 @@ type Attribute struct {
 	ForceNew bool
 }
 
+// ImpliedType returns the type of values of the attribute.
+func (att Attribute) ImpliedType() cty.Type {
+	if att.NestedType != nil {
+		return att.NestedType.ImpliedType()
+	}
+	return att.Type
+}
+
+// Object is the type of an attribute with nested attributes.
+type Object struct {
+	Attributes Attributes
+	Nesting    NestingMode
+
+	MinItems int
+	MaxItems int
+}
+
+// ImpliedType returns the type of values of attributes with the nested
+// attributes.
+func (o Object) ImpliedType() cty.Type {
+	atts := map[string]cty.Type{}
+	for _, att := range o.Attributes {
+		atts[att.Name] = att.ImpliedType()
+	}
+	ty := cty.Object(atts)
+	switch o.Nesting {
+	case NestingList:
+		return cty.List(ty)
+	case NestingSet:
+		return cty.Set(ty)
+	case NestingMap:
+		return cty.Map(ty)
+	}
+	return ty
+}
+
 func (att *Attribute) IsArgument() bool {
 	if att == nil {
 		return false
@@ -125,6 +261,56 @@ func (err *doesNotExistError) Error() string {
 	return "resource does not exist"
 }
 
+// DoesNotExistError returns the error for Read, Update and Delete to return,
+// possibly wrapped, when the remote object is gone. Read and Update then
+// remove the resource from state, Update with an error, and Delete
+// succeeds.
 func DoesNotExistError() error {
 	return &doesNotExistError{}
 }
+
+// isDoesNotExistError reports whether err or an error it wraps was returned
+// by DoesNotExistError.
+func isDoesNotExistError(err error) bool {
+	for err != nil {
+		if _, ok := err.(*doesNotExistError); ok {
+			return true
+		}
+		err = unwrapError(err)
+	}
+	return false
+}
+
+type partialStateError struct {
+	err error
+}
+
+func (err *partialStateError) Error() string {
+	return err.err.Error()
+}
+
+func (err *partialStateError) Cause() error {
+	return err.err
+}
+
+// PartialStateError wraps err to signal that the remote object was created
+// or modified even though Create or Update failed. The current state of the
+// resource is persisted alongside the error so that Terraform records the
+// resource as tainted instead of forgetting about it.
+func PartialStateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &partialStateError{err: err}
+}
+
+// asPartialStateError finds a partialStateError in the cause chain of err.
+func asPartialStateError(err error) (*partialStateError, bool) {
+	for err != nil {
+		if perr, ok := err.(*partialStateError); ok {
+			return perr, true
+		}
+		err = unwrapError(err)
+	}
+	return nil, false
+}
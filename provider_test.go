@@ -0,0 +1,135 @@
+package sdk
+
+import (
+	"context"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+type testProvider struct {
+	resources map[string]func() Resource
+}
+
+func (p *testProvider) Configure(context.Context, string) error { return nil }
+func (p *testProvider) Stop(context.Context) error              { return nil }
+
+func (p *testProvider) Schema() Schema {
+	return Schema{
+		Block: Block{
+			Attributes: []Attribute{
+				{Name: "endpoint", Type: cty.String, Optional: true},
+			},
+		},
+	}
+}
+
+func (p *testProvider) DataSourceSchemas() map[string]Schema { return map[string]Schema{} }
+
+func (p *testProvider) ResourceSchemas() map[string]Schema {
+	m := map[string]Schema{}
+	for n, f := range p.resources {
+		m[n] = f().Schema()
+	}
+	return m
+}
+
+func (p *testProvider) DataSourceFactory(typeName string) DataSource { return nil }
+
+func (p *testProvider) ResourceFactory(typeName string) Resource {
+	return p.resources[typeName]()
+}
+
+func (p *testProvider) UnmarshalState(cty.Value) error { return nil }
+func (p *testProvider) MarshalState() (cty.Value, error) {
+	return cty.ObjectVal(map[string]cty.Value{"endpoint": cty.NullVal(cty.String)}), nil
+}
+
+type testResource struct {
+	ID   string
+	Name string
+	Tags map[string]string
+
+	create func(context.Context, *testResource) error
+	read   func(context.Context, *testResource) error
+	delete func(context.Context, *testResource) error
+}
+
+func (r *testResource) Schema() Schema {
+	return Schema{
+		Block: Block{
+			Attributes: []Attribute{
+				{Name: "id", Type: cty.String, Computed: true},
+				{Name: "name", Type: cty.String, Required: true, ForceNew: true, Description: "The name."},
+				{Name: "tags", Type: cty.Map(cty.String), Optional: true},
+			},
+		},
+	}
+}
+
+func (r *testResource) Read(ctx context.Context) error {
+	if r.read != nil {
+		return r.read(ctx, r)
+	}
+	return nil
+}
+
+func (r *testResource) Create(ctx context.Context) error {
+	if r.create != nil {
+		return r.create(ctx, r)
+	}
+	r.ID = "test-id"
+	return nil
+}
+
+func (r *testResource) Import(ctx context.Context, id string) error {
+	r.ID = id
+	return nil
+}
+
+func (r *testResource) Delete(ctx context.Context) error {
+	if r.delete != nil {
+		return r.delete(ctx, r)
+	}
+	return nil
+}
+
+func (r *testResource) UnmarshalState(v cty.Value) error {
+	if s := v.GetAttr("id"); !s.IsNull() && s.IsKnown() {
+		r.ID = s.AsString()
+	}
+	if s := v.GetAttr("name"); !s.IsNull() && s.IsKnown() {
+		r.Name = s.AsString()
+	}
+	if s := v.GetAttr("tags"); !s.IsNull() && s.IsKnown() {
+		err := gocty.FromCtyValue(s, &r.Tags)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *testResource) MarshalState() (cty.Value, error) {
+	tags := cty.NullVal(cty.Map(cty.String))
+	if r.Tags != nil {
+		var err error
+		tags, err = gocty.ToCtyValue(r.Tags, cty.Map(cty.String))
+		if err != nil {
+			return cty.NilVal, err
+		}
+	}
+	return cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.StringVal(r.ID),
+		"name": cty.StringVal(r.Name),
+		"tags": tags,
+	}), nil
+}
+
+func newTestProvider() *testProvider {
+	return &testProvider{
+		resources: map[string]func() Resource{
+			"test_thing": func() Resource { return &testResource{} },
+		},
+	}
+}
@@ -0,0 +1,155 @@
+package sdk
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// Provisioner is implemented by provisioner plugins. Provisioners can
+// optionally implement Validator to validate their configuration and
+// Stopper to be notified when Terraform stops them.
+type Provisioner interface {
+	// Provision runs the provisioner against the resource reachable via
+	// the connection settings. Anything written to output is streamed to
+	// Terraform line by line.
+	Provision(ctx context.Context, connection map[string]string, output io.Writer) error
+
+	// generated methods
+	Schema() Schema
+	UnmarshalState(cty.Value) error
+	MarshalState() (cty.Value, error)
+}
+
+// connectionType is the type Terraform uses to send connection settings.
+var connectionType = cty.Map(cty.String)
+
+type ProvisionerServer struct {
+	Provisioner Provisioner
+
+	// StopTimeout bounds how long Stop waits for in-flight provisioning
+	// to return after its context is cancelled.
+	StopTimeout time.Duration
+
+	inflight inflightTracker
+}
+
+type GetProvisionerSchemaRequest struct {
+}
+
+type GetProvisionerSchemaResponse struct {
+	Provisioner Schema
+}
+
+func (s *ProvisionerServer) GetSchema(ctx context.Context, req *GetProvisionerSchemaRequest) (*GetProvisionerSchemaResponse, error) {
+	return &GetProvisionerSchemaResponse{
+		Provisioner: s.Provisioner.Schema(),
+	}, nil
+}
+
+type ValidateProvisionerConfigRequest struct {
+	Config DynamicValue
+}
+
+type ValidateProvisionerConfigResponse struct {
+	Diagnostics Diagnostics
+}
+
+func (s *ProvisionerServer) ValidateProvisionerConfig(ctx context.Context, req *ValidateProvisionerConfigRequest) (*ValidateProvisionerConfigResponse, error) {
+	blockType := blockType(s.Provisioner)
+	config, err := req.Config.Unmarshal(blockType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = unmarshalState(s.Provisioner, config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var diags Diagnostics
+	if v, ok := s.Provisioner.(Validator); ok {
+		redactor := newRedactor(schemaOf(s.Provisioner).Block, config)
+		diags = redactor.errorDiagnostics(validate(ctx, v))
+	}
+
+	return &ValidateProvisionerConfigResponse{
+		Diagnostics: diags,
+	}, nil
+}
+
+type ProvisionResourceRequest struct {
+	Config     DynamicValue
+	Connection DynamicValue
+}
+
+type ProvisionResourceResponse struct {
+	Diagnostics Diagnostics
+}
+
+func (s *ProvisionerServer) ProvisionResource(ctx context.Context, req *ProvisionResourceRequest, output io.Writer) (*ProvisionResourceResponse, error) {
+	ctx, done := s.inflight.track(ctx)
+	defer done()
+
+	blockType := blockType(s.Provisioner)
+	config, err := req.Config.Unmarshal(blockType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = unmarshalState(s.Provisioner, config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	connVal, err := req.Connection.Unmarshal(connectionType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	conn, err := connectionSettings(connVal)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = s.Provisioner.Provision(ctx, conn, output)
+	redactor := newRedactor(schemaOf(s.Provisioner).Block, config)
+	diags := redactor.errorDiagnostics(err)
+
+	return &ProvisionResourceResponse{
+		Diagnostics: diags,
+	}, nil
+}
+
+// Stop cancels any in-flight provisioning and waits up to StopTimeout for
+// it to return before stopping the provisioner.
+func (s *ProvisionerServer) Stop(ctx context.Context) error {
+	s.inflight.cancelAndWait(ctx, s.StopTimeout)
+
+	if stopper, ok := s.Provisioner.(Stopper); ok {
+		return stopper.Stop(ctx)
+	}
+	return nil
+}
+
+func connectionSettings(v cty.Value) (map[string]string, error) {
+	conn := map[string]string{}
+	if v.IsNull() || !v.IsKnown() {
+		return conn, nil
+	}
+	for k, setting := range v.AsValueMap() {
+		if setting.IsNull() || !setting.IsKnown() {
+			continue
+		}
+		var s string
+		err := gocty.FromCtyValue(setting, &s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decode connection setting: %s", k)
+		}
+		conn[k] = s
+	}
+	return conn, nil
+}
@@ -0,0 +1,199 @@
+package sdk
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// redactedValue replaces sensitive values.
+const redactedValue = "(sensitive value)"
+
+// minSecretLength is the length below which sensitive values are not
+// scrubbed from diagnostics, as they would mangle unrelated text.
+const minSecretLength = 4
+
+// RedactValue returns val, a value of the schema's block, with the values
+// of all sensitive attributes masked so that it can be logged. The masked
+// attributes are strings and the elements of nested blocks are collected in
+// tuples, as they may no longer share a type, so the result does not
+// conform to the schema.
+func RedactValue(schema Schema, val cty.Value) cty.Value {
+	return redactBlock(schema.Block, val)
+}
+
+func redactBlock(b Block, val cty.Value) cty.Value {
+	if val.IsNull() || !val.IsKnown() || !val.Type().IsObjectType() {
+		return val
+	}
+
+	vals := val.AsValueMap()
+	for _, att := range b.Attributes {
+		v, ok := vals[att.Name]
+		if !ok {
+			continue
+		}
+		if !att.Sensitive {
+			if att.NestedType != nil {
+				vals[att.Name] = redactNestedBlock(nestedTypeBlock(att.NestedType), v)
+			}
+			continue
+		}
+		// always change the type, so elements of nested blocks stay
+		// consistent with each other
+		switch {
+		case v.IsNull():
+			vals[att.Name] = cty.NullVal(cty.String)
+		case !v.IsKnown():
+			vals[att.Name] = cty.UnknownVal(cty.String)
+		default:
+			vals[att.Name] = cty.StringVal(redactedValue)
+		}
+	}
+	for _, nb := range b.BlockTypes {
+		v, ok := vals[nb.TypeName]
+		if !ok {
+			continue
+		}
+		vals[nb.TypeName] = redactNestedBlock(nb, v)
+	}
+	return cty.ObjectVal(vals)
+}
+
+// nestedTypeBlock returns the nested block equivalent to the object of a
+// nested attribute.
+func nestedTypeBlock(o *Object) NestedBlock {
+	return NestedBlock{
+		Nesting: o.Nesting,
+		Block:   Block{Attributes: o.Attributes},
+	}
+}
+
+func redactNestedBlock(nb NestedBlock, val cty.Value) cty.Value {
+	if nb.Nesting == NestingSingle {
+		return redactBlock(nb.Block, val)
+	}
+	if val.IsNull() || !val.IsKnown() || val.LengthInt() == 0 {
+		return val
+	}
+
+	// redacted elements can differ in type, for example where a nested
+	// object is null in one and set in another
+	switch nb.Nesting {
+	case NestingList, NestingSet:
+		var elems []cty.Value
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			elems = append(elems, redactBlock(nb.Block, v))
+		}
+		return cty.TupleVal(elems)
+	case NestingMap:
+		elems := map[string]cty.Value{}
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			elems[k.AsString()] = redactBlock(nb.Block, v)
+		}
+		return cty.ObjectVal(elems)
+	}
+	return val
+}
+
+// redactor scrubs the known values of sensitive attributes from
+// diagnostics.
+type redactor struct {
+	secrets []string
+}
+
+// newRedactor collects the sensitive values of vals, which are values of
+// the block.
+func newRedactor(b Block, vals ...cty.Value) redactor {
+	seen := map[string]bool{}
+	for _, val := range vals {
+		collectSecrets(b, val, seen)
+	}
+
+	var r redactor
+	for s := range seen {
+		r.secrets = append(r.secrets, s)
+	}
+	// replace longer secrets first, in case one contains another
+	sort.Slice(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i]) > len(r.secrets[j])
+	})
+	return r
+}
+
+func collectSecrets(b Block, val cty.Value, seen map[string]bool) {
+	if val.IsNull() || !val.IsKnown() || !val.Type().IsObjectType() {
+		return
+	}
+
+	for _, att := range b.Attributes {
+		if !val.Type().HasAttribute(att.Name) {
+			continue
+		}
+		if !att.Sensitive {
+			if att.NestedType != nil {
+				collectNestedSecrets(nestedTypeBlock(att.NestedType), val.GetAttr(att.Name), seen)
+			}
+			continue
+		}
+		cty.Walk(val.GetAttr(att.Name), func(_ cty.Path, v cty.Value) (bool, error) {
+			if v.IsNull() || !v.IsKnown() || v.Type() == cty.Bool || !v.Type().IsPrimitiveType() {
+				return true, nil
+			}
+			s, err := convert.Convert(v, cty.String)
+			if err == nil && len(s.AsString()) >= minSecretLength {
+				seen[s.AsString()] = true
+			}
+			return true, nil
+		})
+	}
+	for _, nb := range b.BlockTypes {
+		if !val.Type().HasAttribute(nb.TypeName) {
+			continue
+		}
+		collectNestedSecrets(nb, val.GetAttr(nb.TypeName), seen)
+	}
+}
+
+func collectNestedSecrets(nb NestedBlock, val cty.Value, seen map[string]bool) {
+	if nb.Nesting == NestingSingle {
+		collectSecrets(nb.Block, val, seen)
+		return
+	}
+	if val.IsNull() || !val.IsKnown() {
+		return
+	}
+	for it := val.ElementIterator(); it.Next(); {
+		_, ev := it.Element()
+		collectSecrets(nb.Block, ev, seen)
+	}
+}
+
+// errorDiagnostics converts err to diagnostics like errorDiagnostics and
+// scrubs them.
+func (r redactor) errorDiagnostics(err error) Diagnostics {
+	return r.diagnostics(errorDiagnostics(err))
+}
+
+func (r redactor) diagnostics(diags Diagnostics) Diagnostics {
+	if len(r.secrets) == 0 {
+		return diags
+	}
+	for i, d := range diags {
+		d.Summary = r.scrub(d.Summary)
+		d.Detail = r.scrub(d.Detail)
+		diags[i] = d
+	}
+	return diags
+}
+
+func (r redactor) scrub(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.Replace(s, secret, redactedValue, -1)
+	}
+	return s
+}
@@ -0,0 +1,142 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var testSecretBlock = Block{
+	Attributes: []Attribute{
+		{Name: "name", Type: cty.String, Required: true},
+		{Name: "password", Type: cty.String, Optional: true, Sensitive: true},
+		{Name: "pin", Type: cty.Number, Optional: true, Sensitive: true},
+	},
+	BlockTypes: []NestedBlock{
+		{
+			TypeName: "user",
+			Nesting:  NestingList,
+			Block: Block{
+				Attributes: []Attribute{
+					{Name: "token", Type: cty.String, Optional: true, Sensitive: true},
+				},
+			},
+		},
+	},
+}
+
+func testSecretVal() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"name":     cty.StringVal("db"),
+		"password": cty.StringVal("hunter2"),
+		"pin":      cty.NumberIntVal(1),
+		"user": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"token": cty.StringVal("s3cr3t-token")}),
+			cty.ObjectVal(map[string]cty.Value{"token": cty.UnknownVal(cty.String)}),
+		}),
+	})
+}
+
+func TestRedactValue(t *testing.T) {
+	actual := RedactValue(Schema{Block: testSecretBlock}, testSecretVal())
+
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"name":     cty.StringVal("db"),
+		"password": cty.StringVal(redactedValue),
+		"pin":      cty.StringVal(redactedValue),
+		"user": cty.TupleVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"token": cty.StringVal(redactedValue)}),
+			cty.ObjectVal(map[string]cty.Value{"token": cty.UnknownVal(cty.String)}),
+		}),
+	})
+	if !actual.RawEquals(expected) {
+		t.Fatalf("expected %#v, got %#v", expected, actual)
+	}
+}
+
+func TestRedactorErrorDiagnostics(t *testing.T) {
+	redactor := newRedactor(testSecretBlock, testSecretVal())
+
+	err := errors.New("login 1 as db with hunter2 failed, token s3cr3t-token rejected")
+	diags := redactor.errorDiagnostics(err)
+
+	// the short pin is not scrubbed
+	expected := "login 1 as db with (sensitive value) failed, token (sensitive value) rejected"
+	if diags[0].Summary != expected {
+		t.Fatalf("expected %q, got %q", expected, diags[0].Summary)
+	}
+}
+
+func TestRedactNestedAttributes(t *testing.T) {
+	b := Block{
+		Attributes: []Attribute{
+			{
+				Name: "users",
+				NestedType: &Object{
+					Nesting: NestingList,
+					Attributes: []Attribute{
+						{Name: "name", Type: cty.String, Required: true},
+						{Name: "password", Type: cty.String, Optional: true, Sensitive: true},
+					},
+				},
+				Optional: true,
+			},
+		},
+	}
+	val := cty.ObjectVal(map[string]cty.Value{
+		"users": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("admin"), "password": cty.StringVal("hunter2")}),
+		}),
+	})
+
+	actual := RedactValue(Schema{Block: b}, val)
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"users": cty.TupleVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("admin"), "password": cty.StringVal(redactedValue)}),
+		}),
+	})
+	if !actual.RawEquals(expected) {
+		t.Fatalf("expected %#v, got %#v", expected, actual)
+	}
+
+	diags := newRedactor(b, val).errorDiagnostics(errors.New("admin login with hunter2 failed"))
+	if summary := diags[0].Summary; summary != "admin login with (sensitive value) failed" {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+}
+
+func TestRedactValueMixedNestedBlocks(t *testing.T) {
+	auth := NestedBlock{
+		TypeName: "auth",
+		Nesting:  NestingSingle,
+		Block: Block{
+			Attributes: []Attribute{
+				{Name: "pin", Type: cty.Number, Optional: true, Sensitive: true},
+			},
+		},
+	}
+	b := Block{
+		BlockTypes: []NestedBlock{
+			{TypeName: "rule", Nesting: NestingList, Block: Block{BlockTypes: []NestedBlock{auth}}},
+		},
+	}
+	authType := auth.Block.ImpliedType()
+	val := cty.ObjectVal(map[string]cty.Value{
+		"rule": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"auth": cty.NullVal(authType)}),
+			cty.ObjectVal(map[string]cty.Value{"auth": cty.ObjectVal(map[string]cty.Value{"pin": cty.NumberIntVal(1234)})}),
+		}),
+	})
+
+	actual := RedactValue(Schema{Block: b}, val)
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"rule": cty.TupleVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"auth": cty.NullVal(authType)}),
+			cty.ObjectVal(map[string]cty.Value{"auth": cty.ObjectVal(map[string]cty.Value{"pin": cty.StringVal(redactedValue)})}),
+		}),
+	})
+	if !actual.RawEquals(expected) {
+		t.Fatalf("expected %#v, got %#v", expected, actual)
+	}
+}
@@ -0,0 +1,335 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	retryMinWait = 100 * time.Millisecond
+	retryMaxWait = 10 * time.Second
+
+	retryDefaultMinTimeout = 500 * time.Millisecond
+)
+
+// Clock is the source of time for the retry helpers.
+type Clock interface {
+	Now() time.Time
+	After(time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type clockContextKey struct{}
+
+// WithClock returns a context which makes the retry helpers use c instead
+// of the system clock, for example to test them with a fake clock.
+func WithClock(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, clockContextKey{}, c)
+}
+
+func clockFromContext(ctx context.Context) Clock {
+	if c, ok := ctx.Value(clockContextKey{}).(Clock); ok {
+		return c
+	}
+	return realClock{}
+}
+
+// StateRefreshFunc returns the current object, or nil if it does not
+// exist, along with its state.
+type StateRefreshFunc func() (result interface{}, state string, err error)
+
+// StateChangeConf configures waiting for an object to reach a target state.
+type StateChangeConf struct {
+	// Pending are the states in which to keep waiting. Any state that is
+	// neither pending nor a target is an error.
+	Pending []string
+	// Target are the states to wait for.
+	Target []string
+	// Refresh fetches the current state.
+	Refresh StateRefreshFunc
+
+	// Delay is the time to wait before the first refresh.
+	Delay time.Duration
+	// MinTimeout is the smallest time to wait between refreshes when
+	// backing off.
+	MinTimeout time.Duration
+	// PollInterval, if set, is the fixed time to wait between refreshes
+	// instead of backing off.
+	PollInterval time.Duration
+	// NotFoundChecks is the number of consecutive times the object may
+	// not be found before giving up. It defaults to 20.
+	NotFoundChecks int
+	// ContinuousTargetOccurence is the number of consecutive times a
+	// target state must be seen. It defaults to 1.
+	ContinuousTargetOccurence int
+}
+
+// WaitForStateContext refreshes the state until it reaches one of the
+// target states, returning the last refreshed object. It gives up with a
+// TimeoutError when the deadline of ctx passes, and with ctx.Err() when ctx
+// is cancelled.
+func (conf *StateChangeConf) WaitForStateContext(ctx context.Context) (interface{}, error) {
+	clock := clockFromContext(ctx)
+	start := clock.Now()
+
+	notFoundChecks := conf.NotFoundChecks
+	if notFoundChecks <= 0 {
+		notFoundChecks = 20
+	}
+	targetOccurence := conf.ContinuousTargetOccurence
+	if targetOccurence <= 0 {
+		targetOccurence = 1
+	}
+
+	var (
+		result      interface{}
+		lastState   string
+		notFound    int
+		targetCount int
+		wait        time.Duration
+	)
+
+	timeoutError := func() error {
+		return &TimeoutError{
+			LastState:     lastState,
+			ExpectedState: conf.Target,
+			Elapsed:       clock.Now().Sub(start),
+		}
+	}
+
+	sleep := func(d time.Duration) error {
+		if d <= 0 {
+			return nil
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			remaining := deadline.Sub(clock.Now())
+			if remaining <= 0 {
+				return timeoutError()
+			}
+			if d > remaining {
+				d = remaining
+			}
+		}
+		select {
+		case <-clock.After(d):
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				// cancelled, for example by Stop, rather than timed out
+				return ctx.Err()
+			}
+			return timeoutError()
+		}
+		if deadline, ok := ctx.Deadline(); ok && !clock.Now().Before(deadline) {
+			return timeoutError()
+		}
+		return nil
+	}
+
+	err := sleep(conf.Delay)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		result, lastState, err = conf.Refresh()
+		if err != nil {
+			return result, errors.WithStack(err)
+		}
+
+		switch {
+		case result == nil:
+			// the object may not be visible yet, or has been removed
+			targetCount = 0
+			notFound++
+			if notFound > notFoundChecks {
+				return nil, &NotFoundError{
+					Retries: notFound,
+					Elapsed: clock.Now().Sub(start),
+				}
+			}
+		case stringInSlice(lastState, conf.Target):
+			targetCount++
+			if targetCount >= targetOccurence {
+				return result, nil
+			}
+			// reset the wait to check again quickly
+			wait = 0
+		case stringInSlice(lastState, conf.Pending):
+			targetCount = 0
+			notFound = 0
+		default:
+			return result, &UnexpectedStateError{
+				State:         lastState,
+				ExpectedState: conf.Target,
+				Elapsed:       clock.Now().Sub(start),
+			}
+		}
+
+		if conf.PollInterval > 0 {
+			wait = conf.PollInterval
+		} else {
+			wait *= 2
+			if wait < retryMinWait {
+				wait = retryMinWait
+			}
+			if wait < conf.MinTimeout {
+				wait = conf.MinTimeout
+			}
+			if wait > retryMaxWait {
+				wait = retryMaxWait
+			}
+		}
+
+		err = sleep(wait)
+		if err != nil {
+			return result, err
+		}
+	}
+}
+
+// RetryError is returned by a RetryFunc to signal whether to retry.
+type RetryError struct {
+	Err       error
+	Retryable bool
+}
+
+// RetryableError marks err as retryable.
+func RetryableError(err error) *RetryError {
+	if err == nil {
+		return nil
+	}
+	return &RetryError{Err: err, Retryable: true}
+}
+
+// NonRetryableError marks err as not retryable, stopping RetryContext.
+func NonRetryableError(err error) *RetryError {
+	if err == nil {
+		return nil
+	}
+	return &RetryError{Err: err, Retryable: false}
+}
+
+// RetryFunc is called by RetryContext until it returns nil or a non
+// retryable error.
+type RetryFunc func() *RetryError
+
+// RetryContext calls f until it succeeds or returns a non retryable
+// error, backing off between attempts. It gives up with a TimeoutError
+// wrapping the last error when the deadline of ctx passes, and with
+// ctx.Err() when ctx is cancelled.
+func RetryContext(ctx context.Context, f RetryFunc) error {
+	var lastErr error
+	conf := &StateChangeConf{
+		Pending:    []string{"retryableerror"},
+		Target:     []string{"success"},
+		MinTimeout: retryDefaultMinTimeout,
+		Refresh: func() (interface{}, string, error) {
+			rerr := f()
+			switch {
+			case rerr == nil:
+				return 42, "success", nil
+			case rerr.Retryable:
+				lastErr = rerr.Err
+				return 42, "retryableerror", nil
+			default:
+				return nil, "", rerr.Err
+			}
+		},
+	}
+
+	_, err := conf.WaitForStateContext(ctx)
+	if terr, ok := err.(*TimeoutError); ok {
+		terr.LastError = lastErr
+		terr.ExpectedState = nil
+		return terr
+	}
+	return err
+}
+
+// TimeoutError is returned when waiting did not finish in time.
+type TimeoutError struct {
+	LastError     error
+	LastState     string
+	ExpectedState []string
+	Elapsed       time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	var sb strings.Builder
+	if len(e.ExpectedState) > 0 {
+		fmt.Fprintf(&sb, "timeout while waiting for state to become '%s'", strings.Join(e.ExpectedState, ", "))
+	} else {
+		sb.WriteString("timeout while retrying")
+	}
+	fmt.Fprintf(&sb, " (waited %s", e.Elapsed)
+	if e.LastState != "" {
+		fmt.Fprintf(&sb, ", last state: '%s'", e.LastState)
+	}
+	sb.WriteString(")")
+	if e.LastError != nil {
+		fmt.Fprintf(&sb, ": %s", e.LastError)
+	}
+	return sb.String()
+}
+
+func (e *TimeoutError) Diagnostics() Diagnostics {
+	return waitDiagnostics(e, "Timeout while waiting", e.Elapsed)
+}
+
+// NotFoundError is returned when the refreshed object was not found too
+// many times in a row.
+type NotFoundError struct {
+	Retries int
+	Elapsed time.Duration
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("couldn't find resource (%d retries, waited %s)", e.Retries, e.Elapsed)
+}
+
+func (e *NotFoundError) Diagnostics() Diagnostics {
+	return waitDiagnostics(e, "Resource not found while waiting", e.Elapsed)
+}
+
+// UnexpectedStateError is returned when the refreshed state is neither
+// pending nor a target.
+type UnexpectedStateError struct {
+	State         string
+	ExpectedState []string
+	Elapsed       time.Duration
+}
+
+func (e *UnexpectedStateError) Error() string {
+	return fmt.Sprintf("unexpected state '%s', wanted target '%s' (waited %s)", e.State, strings.Join(e.ExpectedState, ", "), e.Elapsed)
+}
+
+func (e *UnexpectedStateError) Diagnostics() Diagnostics {
+	return waitDiagnostics(e, "Unexpected state while waiting", e.Elapsed)
+}
+
+func waitDiagnostics(err error, summary string, elapsed time.Duration) Diagnostics {
+	return Diagnostics{
+		Diagnostic{
+			Severity: SeverityError,
+			Summary:  fmt.Sprintf("%s (waited %s)", summary, elapsed.Round(time.Millisecond)),
+			Detail:   err.Error(),
+		},
+	}
+}
+
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
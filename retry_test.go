@@ -0,0 +1,220 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fakeClock advances instantly whenever it is waited on.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Now()}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func testStates(states ...string) StateRefreshFunc {
+	i := 0
+	return func() (interface{}, string, error) {
+		state := states[i]
+		if i < len(states)-1 {
+			i++
+		}
+		if state == "" {
+			return nil, "", nil
+		}
+		return state, state, nil
+	}
+}
+
+func TestStateChangeConfWaitForStateContext(t *testing.T) {
+	clock := newFakeClock()
+	ctx := WithClock(context.Background(), clock)
+
+	conf := &StateChangeConf{
+		Pending: []string{"CREATING"},
+		Target:  []string{"ACTIVE"},
+		Refresh: testStates("", "CREATING", "CREATING", "ACTIVE"),
+		Delay:   time.Second,
+	}
+	result, err := conf.WaitForStateContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "ACTIVE" {
+		t.Fatalf("unexpected result %v", result)
+	}
+
+	expected := []time.Duration{time.Second, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+	if fmt.Sprint(clock.waits) != fmt.Sprint(expected) {
+		t.Fatalf("expected waits %v, got %v", expected, clock.waits)
+	}
+}
+
+func TestStateChangeConfWaitForStateContextPollInterval(t *testing.T) {
+	clock := newFakeClock()
+	ctx := WithClock(context.Background(), clock)
+
+	conf := &StateChangeConf{
+		Pending:      []string{"CREATING"},
+		Target:       []string{"ACTIVE"},
+		Refresh:      testStates("CREATING", "CREATING", "ACTIVE"),
+		PollInterval: 5 * time.Second,
+	}
+	_, err := conf.WaitForStateContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Duration{5 * time.Second, 5 * time.Second}
+	if fmt.Sprint(clock.waits) != fmt.Sprint(expected) {
+		t.Fatalf("expected waits %v, got %v", expected, clock.waits)
+	}
+}
+
+func TestStateChangeConfWaitForStateContextTimeout(t *testing.T) {
+	clock := newFakeClock()
+	ctx, cancel := context.WithDeadline(WithClock(context.Background(), clock), clock.Now().Add(time.Minute))
+	defer cancel()
+
+	conf := &StateChangeConf{
+		Pending: []string{"CREATING"},
+		Target:  []string{"ACTIVE"},
+		Refresh: testStates("CREATING"),
+	}
+	_, err := conf.WaitForStateContext(ctx)
+	terr, ok := err.(*TimeoutError)
+	if !ok {
+		t.Fatalf("expected timeout error, got %#v", err)
+	}
+	if terr.Elapsed != time.Minute {
+		t.Fatalf("expected to wait 1m, waited %s", terr.Elapsed)
+	}
+	if terr.LastState != "CREATING" {
+		t.Fatalf("unexpected last state %q", terr.LastState)
+	}
+
+	diags := errorDiagnostics(err)
+	if diags[0].Summary != "Timeout while waiting (waited 1m0s)" {
+		t.Fatalf("unexpected summary %q", diags[0].Summary)
+	}
+}
+
+func TestStateChangeConfWaitForStateContextNotFound(t *testing.T) {
+	ctx := WithClock(context.Background(), newFakeClock())
+
+	conf := &StateChangeConf{
+		Pending:        []string{"CREATING"},
+		Target:         []string{"ACTIVE"},
+		Refresh:        testStates(""),
+		NotFoundChecks: 3,
+	}
+	_, err := conf.WaitForStateContext(ctx)
+	if nerr, ok := err.(*NotFoundError); !ok || nerr.Retries != 4 {
+		t.Fatalf("expected not found error, got %#v", err)
+	}
+}
+
+func TestStateChangeConfWaitForStateContextUnexpected(t *testing.T) {
+	ctx := WithClock(context.Background(), newFakeClock())
+
+	conf := &StateChangeConf{
+		Pending: []string{"CREATING"},
+		Target:  []string{"ACTIVE"},
+		Refresh: testStates("CREATING", "FAILED"),
+	}
+	_, err := conf.WaitForStateContext(ctx)
+	if uerr, ok := err.(*UnexpectedStateError); !ok || uerr.State != "FAILED" {
+		t.Fatalf("expected unexpected state error, got %#v", err)
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	ctx := WithClock(context.Background(), newFakeClock())
+
+	calls := 0
+	err := RetryContext(ctx, func() *RetryError {
+		calls++
+		if calls < 3 {
+			return RetryableError(errors.New("not yet"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRetryContextNonRetryable(t *testing.T) {
+	ctx := WithClock(context.Background(), newFakeClock())
+
+	expected := errors.New("bad request")
+	calls := 0
+	err := RetryContext(ctx, func() *RetryError {
+		calls++
+		return NonRetryableError(expected)
+	})
+	if errors.Cause(err) != expected {
+		t.Fatalf("expected %v, got %v", expected, err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetryContextTimeout(t *testing.T) {
+	clock := newFakeClock()
+	ctx, cancel := context.WithDeadline(WithClock(context.Background(), clock), clock.Now().Add(time.Minute))
+	defer cancel()
+
+	expected := errors.New("still busy")
+	err := RetryContext(ctx, func() *RetryError {
+		return RetryableError(expected)
+	})
+	terr, ok := err.(*TimeoutError)
+	if !ok {
+		t.Fatalf("expected timeout error, got %#v", err)
+	}
+	if terr.LastError != expected {
+		t.Fatalf("expected last error %v, got %v", expected, terr.LastError)
+	}
+}
+
+func TestRetryContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := RetryContext(ctx, func() *RetryError {
+		return RetryableError(errors.New("still busy"))
+	})
+	// a cancellation, such as by Stop, is not a timeout
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %#v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package sdk
+
+import (
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// cachedSchema is the schema of a provider, resource or data source,
+// including any SDK managed blocks, and its implied type.
+type cachedSchema struct {
+	Schema Schema
+	Type   cty.Type
+}
+
+func newCachedSchema(s Schema) *cachedSchema {
+	return &cachedSchema{
+		Schema: s,
+		Type:   s.Block.ImpliedType(),
+	}
+}
+
+// schemaCache holds the schemas of a provider, built once on first use, as
+// calling Schema and deriving implied types on every RPC is costly for
+// providers with many resources.
+type schemaCache struct {
+	once sync.Once
+
+	provider    *cachedSchema
+	resources   map[string]*cachedSchema
+	dataSources map[string]*cachedSchema
+}
+
+func (s *Server) schemas() *schemaCache {
+	c := &s.schemaCache
+	c.once.Do(func() {
+		c.provider = newCachedSchema(s.Provider.Schema())
+
+		c.resources = map[string]*cachedSchema{}
+		for n, schema := range s.Provider.ResourceSchemas() {
+			c.resources[n] = newCachedSchema(injectSchema(s.Provider.ResourceFactory(n), schema))
+		}
+
+		c.dataSources = map[string]*cachedSchema{}
+		for n, schema := range s.Provider.DataSourceSchemas() {
+			c.dataSources[n] = newCachedSchema(injectSchema(s.Provider.DataSourceFactory(n), schema))
+		}
+	})
+	return c
+}
+
+// resourceSchema returns the cached schema of the resource type, falling
+// back to the schema of r for types the provider does not declare.
+func (s *Server) resourceSchema(typeName string, r Resource) *cachedSchema {
+	if cs, ok := s.schemas().resources[typeName]; ok {
+		return cs
+	}
+	return newCachedSchema(schemaOf(r))
+}
+
+// dataSourceSchema returns the cached schema of the data source type,
+// falling back to the schema of ds for types the provider does not declare.
+func (s *Server) dataSourceSchema(typeName string, ds DataSource) *cachedSchema {
+	if cs, ok := s.schemas().dataSources[typeName]; ok {
+		return cs
+	}
+	return newCachedSchema(schemaOf(ds))
+}
@@ -0,0 +1,158 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+// testCountingProvider counts the calls to Schema and ResourceSchemas.
+type testCountingProvider struct {
+	*testProvider
+	schemaCalls          int
+	resourceSchemasCalls int
+}
+
+func (p *testCountingProvider) Schema() Schema {
+	p.schemaCalls++
+	return p.testProvider.Schema()
+}
+
+func (p *testCountingProvider) ResourceSchemas() map[string]Schema {
+	p.resourceSchemasCalls++
+	return p.testProvider.ResourceSchemas()
+}
+
+func TestServerSchemaCache(t *testing.T) {
+	ctx := context.Background()
+	p := &testCountingProvider{testProvider: newTestProvider()}
+	s := &GRPCProviderServer{Server: Server{Provider: p}}
+
+	for i := 0; i < 3; i++ {
+		_, err := s.GetSchema(ctx, &pb.GetProviderSchema_Request{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Server.PrepareProviderConfig(ctx, &PrepareProviderConfigRequest{
+			Config: testMsgpack(t, cty.ObjectVal(map[string]cty.Value{"endpoint": cty.NullVal(cty.String)})),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Server.ValidateResourceTypeConfig(ctx, &ValidateResourceTypeConfigRequest{
+			TypeName: "test_thing",
+			Config:   testMsgpack(t, testThingVal("", "foo", nil)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if p.schemaCalls != 1 || p.resourceSchemasCalls != 1 {
+		t.Fatalf("expected schemas to be built once, got %d Schema and %d ResourceSchemas calls", p.schemaCalls, p.resourceSchemasCalls)
+	}
+}
+
+// newBenchmarkServer returns a server of a provider with n resource types.
+func newBenchmarkServer(n int) *Server {
+	p := &testProvider{resources: map[string]func() Resource{}}
+	for i := 0; i < n; i++ {
+		p.resources[fmt.Sprintf("test_thing_%d", i)] = func() Resource { return &testResource{} }
+	}
+	return &Server{Provider: p}
+}
+
+func benchmarkMsgpack(b *testing.B, v cty.Value) DynamicValue {
+	dv, err := NewDynamicValue(v, v.Type(), false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return dv
+}
+
+func BenchmarkServerGetSchema(b *testing.B) {
+	s := newBenchmarkServer(500)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := s.GetSchema(ctx, &GetSchemaRequest{})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServerValidateResourceTypeConfig(b *testing.B) {
+	s := newBenchmarkServer(500)
+	ctx := context.Background()
+	req := &ValidateResourceTypeConfigRequest{
+		TypeName: "test_thing_0",
+		Config:   benchmarkMsgpack(b, testThingVal("", "foo", nil)),
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := s.ValidateResourceTypeConfig(ctx, req)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServerReadResource(b *testing.B) {
+	s := newBenchmarkServer(500)
+	testConfigure(b, s)
+	ctx := context.Background()
+	req := &ReadResourceRequest{
+		TypeName:     "test_thing_0",
+		CurrentState: benchmarkMsgpack(b, testThingVal("test-id", "foo", nil)),
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := s.ReadResource(ctx, req)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServerPlanResourceChange(b *testing.B) {
+	s := newBenchmarkServer(500)
+	ctx := context.Background()
+	prior := testThingVal("test-id", "foo", map[string]cty.Value{"k1": cty.StringVal("v1")})
+	proposed := testThingVal("test-id", "foo", map[string]cty.Value{"k1": cty.StringVal("v2")})
+	req := &PlanResourceChangeRequest{
+		TypeName:         "test_thing_0",
+		PriorState:       benchmarkMsgpack(b, prior),
+		Config:           benchmarkMsgpack(b, proposed),
+		ProposedNewState: benchmarkMsgpack(b, proposed),
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := s.PlanResourceChange(ctx, req)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServerApplyResourceChange(b *testing.B) {
+	s := newBenchmarkServer(500)
+	testConfigure(b, s)
+	ctx := context.Background()
+	req := &ApplyResourceChangeRequest{
+		TypeName:     "test_thing_0",
+		PriorState:   benchmarkMsgpack(b, cty.NullVal(testThingVal("", "", nil).Type())),
+		PlannedState: benchmarkMsgpack(b, testThingVal("", "foo", nil)),
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := s.ApplyResourceChange(ctx, req)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
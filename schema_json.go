@@ -0,0 +1,148 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+// schemaJSONFormatVersion matches the format_version emitted by
+// `terraform providers schema -json`.
+const schemaJSONFormatVersion = "0.1"
+
+type jsonProviderSchemas struct {
+	FormatVersion   string                         `json:"format_version"`
+	ProviderSchemas map[string]*jsonProviderSchema `json:"provider_schemas,omitempty"`
+}
+
+type jsonProviderSchema struct {
+	Provider          *jsonSchema            `json:"provider,omitempty"`
+	ResourceSchemas   map[string]*jsonSchema `json:"resource_schemas,omitempty"`
+	DataSourceSchemas map[string]*jsonSchema `json:"data_source_schemas,omitempty"`
+}
+
+type jsonSchema struct {
+	Version int64      `json:"version"`
+	Block   *jsonBlock `json:"block,omitempty"`
+}
+
+type jsonBlock struct {
+	Attributes map[string]*jsonAttribute   `json:"attributes,omitempty"`
+	BlockTypes map[string]*jsonNestedBlock `json:"block_types,omitempty"`
+}
+
+type jsonAttribute struct {
+	AttributeType json.RawMessage `json:"type,omitempty"`
+	Description   string          `json:"description,omitempty"`
+	Required      bool            `json:"required,omitempty"`
+	Optional      bool            `json:"optional,omitempty"`
+	Computed      bool            `json:"computed,omitempty"`
+	Sensitive     bool            `json:"sensitive,omitempty"`
+}
+
+type jsonNestedBlock struct {
+	Block       *jsonBlock `json:"block,omitempty"`
+	NestingMode string     `json:"nesting_mode,omitempty"`
+	MinItems    int64      `json:"min_items,omitempty"`
+	MaxItems    int64      `json:"max_items,omitempty"`
+}
+
+func jsonSchemaBlock(v *pb.Schema_Block) *jsonBlock {
+	if v == nil {
+		return nil
+	}
+
+	block := &jsonBlock{}
+	if len(v.Attributes) > 0 {
+		block.Attributes = make(map[string]*jsonAttribute, len(v.Attributes))
+		for _, att := range v.Attributes {
+			block.Attributes[att.Name] = &jsonAttribute{
+				AttributeType: json.RawMessage(att.Type),
+				Description:   att.Description,
+				Required:      att.Required,
+				Optional:      att.Optional,
+				Computed:      att.Computed,
+				Sensitive:     att.Sensitive,
+			}
+		}
+	}
+	if len(v.BlockTypes) > 0 {
+		block.BlockTypes = make(map[string]*jsonNestedBlock, len(v.BlockTypes))
+		for _, nb := range v.BlockTypes {
+			block.BlockTypes[nb.TypeName] = &jsonNestedBlock{
+				Block:       jsonSchemaBlock(nb.Block),
+				NestingMode: strings.ToLower(nb.Nesting.String()),
+				MinItems:    nb.MinItems,
+				MaxItems:    nb.MaxItems,
+			}
+		}
+	}
+	return block
+}
+
+func jsonSchemaFromPB(v *pb.Schema) *jsonSchema {
+	if v == nil {
+		return nil
+	}
+	return &jsonSchema{
+		Version: v.Version,
+		Block:   jsonSchemaBlock(v.Block),
+	}
+}
+
+func jsonMapSchemaFromPB(v map[string]*pb.Schema) map[string]*jsonSchema {
+	if len(v) == 0 {
+		return nil
+	}
+
+	m := make(map[string]*jsonSchema, len(v))
+	for k, s := range v {
+		m[k] = jsonSchemaFromPB(s)
+	}
+	return m
+}
+
+// WriteSchemaJSON writes the provider, resource and data source schemas
+// of p to w in the format used by `terraform providers schema -json`.
+// The providerName is used as the key in the provider_schemas map.
+func WriteSchemaJSON(ctx context.Context, w io.Writer, providerName string, p Provider) error {
+	s := &GRPCProviderServer{
+		Server: Server{
+			Provider: p,
+		},
+	}
+	return writeSchemaJSON(ctx, w, providerName, s)
+}
+
+func writeSchemaJSON(ctx context.Context, w io.Writer, providerName string, s pb.ProviderServer) error {
+	resp, err := s.GetSchema(ctx, &pb.GetProviderSchema_Request{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return encodeSchemaJSON(w, providerName, resp)
+}
+
+func encodeSchemaJSON(w io.Writer, providerName string, resp *pb.GetProviderSchema_Response) error {
+	doc := &jsonProviderSchemas{
+		FormatVersion: schemaJSONFormatVersion,
+		ProviderSchemas: map[string]*jsonProviderSchema{
+			providerName: {
+				Provider:          jsonSchemaFromPB(resp.Provider),
+				ResourceSchemas:   jsonMapSchemaFromPB(resp.ResourceSchemas),
+				DataSourceSchemas: jsonMapSchemaFromPB(resp.DataSourceSchemas),
+			},
+		},
+	}
+
+	enc := json.NewEncoder(w)
+	err := enc.Encode(doc)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode schema JSON")
+	}
+	return nil
+}
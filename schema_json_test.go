@@ -0,0 +1,87 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestWriteSchemaJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteSchemaJSON(context.Background(), &buf, "test", newTestProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		FormatVersion   string `json:"format_version"`
+		ProviderSchemas map[string]struct {
+			Provider        map[string]interface{} `json:"provider"`
+			ResourceSchemas map[string]struct {
+				Block struct {
+					Attributes map[string]map[string]interface{} `json:"attributes"`
+				} `json:"block"`
+			} `json:"resource_schemas"`
+		} `json:"provider_schemas"`
+	}
+	err = json.Unmarshal(buf.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.FormatVersion != schemaJSONFormatVersion {
+		t.Fatalf("unexpected format version %q", doc.FormatVersion)
+	}
+	atts := doc.ProviderSchemas["test"].ResourceSchemas["test_thing"].Block.Attributes
+	if len(atts) != 3 {
+		t.Fatalf("expected 3 attributes, got %d", len(atts))
+	}
+	if actual := fmt.Sprint(atts["tags"]["type"]); actual != "[map string]" {
+		t.Fatalf("unexpected tags type %s", actual)
+	}
+	if atts["name"]["required"] != true || atts["name"]["description"] != "The name." {
+		t.Fatalf("unexpected name attribute %#v", atts["name"])
+	}
+}
+
+func TestProviderNameFromBinary(t *testing.T) {
+	for i, c := range []struct {
+		expected string
+		path     string
+	}{
+		{"tls", "/plugins/terraform-provider-tls"},
+		{"tls", "terraform-provider-tls_v2.0.0"},
+		{"tls", `terraform-provider-tls_v2.0.0_x4.exe`},
+		{"foo", "foo"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.path), func(t *testing.T) {
+			actual := providerNameFromBinary(c.path)
+			if actual != c.expected {
+				t.Fatalf("expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNewServeConfigFlags(t *testing.T) {
+	args := []string{"-schema-json", "-custom", "positional"}
+
+	// arguments are left to the provider unless it asks for the flags
+	conf, err := newServeConfig(args, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.schemaJSON != nil || conf.debug {
+		t.Fatal("expected flags to be ignored")
+	}
+
+	conf, err = newServeConfig([]string{"-schema-json"}, []ServeOpt{WithFlags()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.schemaJSON == nil {
+		t.Fatal("expected -schema-json to be parsed")
+	}
+}
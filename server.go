@@ -2,14 +2,31 @@ package sdk
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/zclconf/go-cty/cty"
-	"github.com/zclconf/go-cty/cty/msgpack"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 type Server struct {
 	Provider Provider
+
+	// StopTimeout bounds how long Stop waits for in-flight operations to
+	// return after their contexts are cancelled.
+	StopTimeout time.Duration
+
+	// EncodeJSON makes the server answer with JSON encoded values instead
+	// of msgpack, for debugging. Values which are not wholly known are
+	// still encoded as msgpack, as JSON cannot represent unknowns.
+	EncodeJSON bool
+
+	inflight    inflightTracker
+	schemaCache schemaCache
+	configure   configureGate
+	concurrency concurrencyLimits
 }
 
 type GetSchemaRequest struct {
@@ -22,25 +39,37 @@ type GetSchemaResponse struct {
 }
 
 func (s *Server) GetSchema(ctx context.Context, req *GetSchemaRequest) (*GetSchemaResponse, error) {
+	schemas := s.schemas()
+
+	dataSourceSchemas := make(map[string]Schema, len(schemas.dataSources))
+	for n, cs := range schemas.dataSources {
+		dataSourceSchemas[n] = cs.Schema
+	}
+
+	resourceSchemas := make(map[string]Schema, len(schemas.resources))
+	for n, cs := range schemas.resources {
+		resourceSchemas[n] = cs.Schema
+	}
+
 	return &GetSchemaResponse{
-		Provider:          s.Provider.Schema(),
-		DataSourceSchemas: s.Provider.DataSourceSchemas(),
-		ResourceSchemas:   s.Provider.ResourceSchemas(),
+		Provider:          schemas.provider.Schema,
+		DataSourceSchemas: dataSourceSchemas,
+		ResourceSchemas:   resourceSchemas,
 	}, nil
 }
 
 type PrepareProviderConfigRequest struct {
-	Config []byte
+	Config DynamicValue
 }
 
 type PrepareProviderConfigResponse struct {
-	PreparedConfig []byte
+	PreparedConfig DynamicValue
 	Diagnostics    Diagnostics
 }
 
 func (s *Server) PrepareProviderConfig(ctx context.Context, req *PrepareProviderConfigRequest) (*PrepareProviderConfigResponse, error) {
-	blockType := blockType(s.Provider)
-	config, err := msgpack.Unmarshal(req.Config, blockType)
+	schema := s.schemas().provider
+	config, err := req.Config.Unmarshal(schema.Type)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -50,13 +79,10 @@ func (s *Server) PrepareProviderConfig(ctx context.Context, req *PrepareProvider
 		return nil, errors.WithStack(err)
 	}
 
+	redactor := newRedactor(schema.Schema.Block, config)
 	var diags Diagnostics
 	if v, ok := s.Provider.(Validator); ok {
-		err := v.Validate()
-		diags, err = errorOrDiagnostics(err)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
+		diags = redactor.errorDiagnostics(validate(ctx, v))
 		if diags.IsError() {
 			return &PrepareProviderConfigResponse{
 				Diagnostics: diags,
@@ -69,7 +95,7 @@ func (s *Server) PrepareProviderConfig(ctx context.Context, req *PrepareProvider
 		return nil, errors.WithStack(err)
 	}
 
-	data, err := msgpack.Marshal(state, blockType)
+	data, err := s.encode(state, schema.Type)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to marshal state for provider block")
 	}
@@ -82,7 +108,7 @@ func (s *Server) PrepareProviderConfig(ctx context.Context, req *PrepareProvider
 
 type ValidateResourceTypeConfigRequest struct {
 	TypeName string
-	Config   []byte
+	Config   DynamicValue
 }
 
 type ValidateResourceTypeConfigResponse struct {
@@ -91,8 +117,8 @@ type ValidateResourceTypeConfigResponse struct {
 
 func (s *Server) ValidateResourceTypeConfig(ctx context.Context, req *ValidateResourceTypeConfigRequest) (*ValidateResourceTypeConfigResponse, error) {
 	r := s.Provider.ResourceFactory(req.TypeName)
-	blockType := blockType(r)
-	config, err := msgpack.Unmarshal(req.Config, blockType)
+	schema := s.resourceSchema(req.TypeName, r)
+	config, err := req.Config.Unmarshal(schema.Type)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -102,13 +128,10 @@ func (s *Server) ValidateResourceTypeConfig(ctx context.Context, req *ValidateRe
 		return nil, errors.WithStack(err)
 	}
 
-	var diags Diagnostics
+	diags := validateTimeouts(r, config)
 	if v, ok := r.(Validator); ok {
-		err := v.Validate()
-		diags, err = errorOrDiagnostics(err)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
+		redactor := newRedactor(schema.Schema.Block, config)
+		diags = append(diags, redactor.errorDiagnostics(validate(ctx, v))...)
 	}
 
 	return &ValidateResourceTypeConfigResponse{
@@ -118,7 +141,7 @@ func (s *Server) ValidateResourceTypeConfig(ctx context.Context, req *ValidateRe
 
 type ValidateDataSourceConfigRequest struct {
 	TypeName string
-	Config   []byte
+	Config   DynamicValue
 }
 
 type ValidateDataSourceConfigResponse struct {
@@ -127,8 +150,8 @@ type ValidateDataSourceConfigResponse struct {
 
 func (s *Server) ValidateDataSourceConfig(ctx context.Context, req *ValidateDataSourceConfigRequest) (*ValidateDataSourceConfigResponse, error) {
 	ds := s.Provider.DataSourceFactory(req.TypeName)
-	blockType := blockType(ds)
-	config, err := msgpack.Unmarshal(req.Config, blockType)
+	schema := s.dataSourceSchema(req.TypeName, ds)
+	config, err := req.Config.Unmarshal(schema.Type)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -138,13 +161,10 @@ func (s *Server) ValidateDataSourceConfig(ctx context.Context, req *ValidateData
 		return nil, errors.WithStack(err)
 	}
 
-	var diags Diagnostics
+	diags := validateTimeouts(ds, config)
 	if v, ok := ds.(Validator); ok {
-		err := v.Validate()
-		diags, err = errorOrDiagnostics(err)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
+		redactor := newRedactor(schema.Schema.Block, config)
+		diags = append(diags, redactor.errorDiagnostics(validate(ctx, v))...)
 	}
 
 	return &ValidateDataSourceConfigResponse{
@@ -153,17 +173,88 @@ func (s *Server) ValidateDataSourceConfig(ctx context.Context, req *ValidateData
 }
 
 type UpgradeResourceStateRequest struct {
+	TypeName string
+	Version  int
+
+	// RawStateJSON is the state as stored by Terraform 0.12 and later.
+	RawStateJSON []byte
+	// RawStateFlatmap is the legacy state as stored by the helper/schema
+	// SDK, only present if RawStateJSON is not.
+	RawStateFlatmap map[string]string
 }
 
 type UpgradeResourceStateResponse struct {
+	Diagnostics   Diagnostics
+	UpgradedState DynamicValue
 }
 
-func (s *Server) UpgradeResourceState(context.Context, *UpgradeResourceStateRequest) (*UpgradeResourceStateResponse, error) {
-	panic("UpgradeResourceState not implemented")
+func (s *Server) UpgradeResourceState(ctx context.Context, req *UpgradeResourceStateRequest) (*UpgradeResourceStateResponse, error) {
+	r := s.Provider.ResourceFactory(req.TypeName)
+	schema := s.resourceSchema(req.TypeName, r)
+	blockType := schema.Type
+
+	var (
+		state cty.Value
+		err   error
+	)
+	switch {
+	case req.Version > schema.Schema.Version:
+		return &UpgradeResourceStateResponse{
+			Diagnostics: Diagnostics{
+				Diagnostic{
+					Severity: SeverityError,
+					Summary:  "Resource state written by a newer provider",
+					Detail: fmt.Sprintf("The state of %s was written with schema version %d, but this version of the provider "+
+						"only supports up to version %d. Upgrade the provider to use this state.", req.TypeName, req.Version, schema.Schema.Version),
+				},
+			},
+		}, nil
+	case req.Version < schema.Schema.Version:
+		upgrader, ok := r.(StateUpgrader)
+		if !ok || len(req.RawStateJSON) == 0 {
+			return &UpgradeResourceStateResponse{
+				Diagnostics: Diagnostics{
+					Diagnostic{
+						Severity: SeverityError,
+						Summary:  "Unable to upgrade resource state",
+						Detail: fmt.Sprintf("The state of %s was written with schema version %d, and the provider "+
+							"cannot upgrade it to version %d. This is a bug in the provider.", req.TypeName, req.Version, schema.Schema.Version),
+					},
+				},
+			}, nil
+		}
+		state, err = upgrader.UpgradeState(ctx, req.Version, req.RawStateJSON)
+		if err == nil {
+			state, err = convert.Convert(state, blockType)
+		}
+		err = errors.Wrapf(err, "unable to upgrade state for resource %s from version %d", req.TypeName, req.Version)
+	case len(req.RawStateJSON) > 0:
+		state, err = ctyjson.Unmarshal(req.RawStateJSON, blockType)
+		err = errors.Wrapf(err, "unable to unmarshal state for resource: %s", req.TypeName)
+	case req.RawStateFlatmap != nil:
+		state, err = flatmapValue(req.RawStateFlatmap, schema.Schema.Block)
+		err = errors.Wrapf(err, "unable to migrate flatmap state for resource: %s", req.TypeName)
+	default:
+		state = cty.NullVal(blockType)
+	}
+	if err != nil {
+		return &UpgradeResourceStateResponse{
+			Diagnostics: errorDiagnostics(err),
+		}, nil
+	}
+
+	data, err := s.encode(state, blockType)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to marshal state for resource: %s", req.TypeName)
+	}
+
+	return &UpgradeResourceStateResponse{
+		UpgradedState: data,
+	}, nil
 }
 
 type ConfigureRequest struct {
-	Config           []byte
+	Config           DynamicValue
 	TerraformVersion string
 }
 
@@ -171,9 +262,20 @@ type ConfigureResponse struct {
 	Diagnostics Diagnostics
 }
 
+// Configure configures the provider. RPCs calling resource or data source
+// code fail until it has succeeded, and wait for it while it is running.
 func (s *Server) Configure(ctx context.Context, req *ConfigureRequest) (*ConfigureResponse, error) {
-	blockType := blockType(s.Provider)
-	config, err := msgpack.Unmarshal(req.Config, blockType)
+	ctx, done := s.inflight.track(ctx)
+	defer done()
+
+	configured := false
+	finish := s.configure.begin()
+	defer func() {
+		finish(configured)
+	}()
+
+	schema := s.schemas().provider
+	config, err := req.Config.Unmarshal(schema.Type)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -183,11 +285,10 @@ func (s *Server) Configure(ctx context.Context, req *ConfigureRequest) (*Configu
 		return nil, errors.WithStack(err)
 	}
 
-	err = s.Provider.Configure(ctx, req.TerraformVersion)
-	diags, err := errorOrDiagnostics(err)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
+	err = s.Provider.Configure(withTerraformVersion(ctx, req.TerraformVersion), req.TerraformVersion)
+	redactor := newRedactor(schema.Schema.Block, config)
+	diags := redactor.errorDiagnostics(err)
+	configured = !diags.IsError()
 
 	return &ConfigureResponse{
 		Diagnostics: diags,
@@ -196,32 +297,50 @@ func (s *Server) Configure(ctx context.Context, req *ConfigureRequest) (*Configu
 
 type ReadResourceRequest struct {
 	TypeName     string
-	CurrentState []byte
+	CurrentState DynamicValue
 }
 
 type ReadResourceResponse struct {
 	Diagnostics Diagnostics
-	NewState    []byte
+	NewState    DynamicValue
 }
 
 func (s *Server) ReadResource(ctx context.Context, req *ReadResourceRequest) (*ReadResourceResponse, error) {
+	ctx, done := s.inflight.track(ctx)
+	defer done()
+
+	if diags := s.configure.wait(ctx, "ReadResource"); diags.IsError() {
+		return &ReadResourceResponse{
+			Diagnostics: diags,
+		}, nil
+	}
+
+	release, err := s.acquireResource(ctx, req.TypeName)
+	if err != nil {
+		return &ReadResourceResponse{
+			Diagnostics: errorDiagnostics(errors.Wrapf(err, "cancelled waiting to run ReadResource for %s", req.TypeName)),
+		}, nil
+	}
+	defer release()
+
 	r := s.Provider.ResourceFactory(req.TypeName)
-	blockType := blockType(r)
-	current, err := msgpack.Unmarshal(req.CurrentState, blockType)
+	schema := s.resourceSchema(req.TypeName, r)
+	current, err := decodeState(ctx, r, schema.Type, req.CurrentState)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	err = unmarshalState(r, current)
+	opCtx, cancel, timeout, err := operationContext(ctx, r, current, operationRead)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	defer cancel()
 
-	err = r.Read(ctx)
-	if _, ok := err.(*doesNotExistError); ok {
+	err = traced(opCtx, "read", r.Read)
+	if isDoesNotExistError(err) {
 		// resource does not exist, return empty state
-		state := cty.NullVal(blockType)
-		data, err := msgpack.Marshal(state, blockType)
+		state := cty.NullVal(schema.Type)
+		data, err := s.encode(state, schema.Type)
 		if err != nil {
 			return nil, errors.Wrapf(err, "unable to marshal state for resource: %s", req.TypeName)
 		}
@@ -230,22 +349,16 @@ func (s *Server) ReadResource(ctx context.Context, req *ReadResourceRequest) (*R
 			NewState: data,
 		}, nil
 	}
-	diags, err := errorOrDiagnostics(err)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
+	err = timeoutError(opCtx, operationRead, timeout, err)
+	redactor := newRedactor(schema.Schema.Block, current)
+	diags := redactor.errorDiagnostics(err)
 	if diags.IsError() {
 		return &ReadResourceResponse{
 			Diagnostics: diags,
 		}, nil
 	}
 
-	state, err := r.MarshalState()
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
-	data, err := msgpack.Marshal(state, blockType)
+	data, err := s.encodeState(ctx, r, schema.Type, current)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to marshal state for resource: %s", req.TypeName)
 	}
@@ -257,84 +370,67 @@ func (s *Server) ReadResource(ctx context.Context, req *ReadResourceRequest) (*R
 }
 
 type change struct {
-	Path      cty.Path
-	Attribute Attribute
-	From      cty.Value
-	To        cty.Value
+	// Path is the precise path of the change, see valueDiff.
+	Path cty.Path
+	// AddressablePath is the path to report over the wire.
+	AddressablePath cty.Path
+	Attribute       Attribute
+	From            cty.Value
+	To              cty.Value
 }
 
-// TODO: replace this wity cty's diff stuff?
-func changes(r Resource, from, to cty.Value) ([]change, error) {
-	schemaBlock := r.Schema().Block
-
+func changes(schemaBlock Block, from, to cty.Value) ([]change, error) {
 	var changes []change
-
-	// Walk to to see if anything has changed and
-	// would require replacement (force new)
-	// TODO: swap this once cty implements NewDiff...
-	err := cty.Walk(to, func(path cty.Path, toVal cty.Value) (bool, error) {
-		if len(path) == 0 {
-			// skip root
-			return true, nil
+	for _, d := range diffValues(from, to) {
+		if len(d.Path) == 0 {
+			return nil, errors.Errorf("unable to compute changes for resource object, from null: %t, to null: %t", from.IsNull(), to.IsNull())
 		}
 
-		schemaAtt, err := schemaBlock.ApplyPath(path)
+		schemaAtt, err := schemaBlock.ApplyPath(d.Path)
 		if err != nil {
-			return false, errors.Wrapf(err, "unable to apply path to schema block for path: %#v", path)
+			return nil, errors.Wrapf(err, "unable to apply path to schema block for path: %#v", d.Path)
 		}
 		if schemaAtt == nil {
-			return false, errors.Errorf("path not found in schema: %v", path)
-		}
-
-		fromVal, err := path.Apply(from)
-		if err != nil {
-			return false, errors.Wrap(err, "unable to apply path to prior state")
-		}
-
-		equal := fromVal.Equals(toVal)
-		if equal.IsKnown() && equal.True() {
-			return false, nil
+			return nil, errors.Errorf("path not found in schema: %v", d.Path)
 		}
 
 		changes = append(changes, change{
-			Path:      path,
-			Attribute: *schemaAtt,
-			From:      fromVal,
-			To:        toVal,
+			Path:            d.Path,
+			AddressablePath: d.AddressablePath,
+			Attribute:       *schemaAtt,
+			From:            d.From,
+			To:              d.To,
 		})
-		return false, nil
-	})
-	if err != nil {
-		return nil, errors.WithStack(err)
 	}
 	return changes, nil
 }
 
 type PlanResourceChangeRequest struct {
 	TypeName         string
-	Config           []byte
-	PriorState       []byte
-	ProposedNewState []byte
+	Config           DynamicValue
+	PriorState       DynamicValue
+	ProposedNewState DynamicValue
 }
 
 type PlanResourceChangeResponse struct {
 	Diagnostics     Diagnostics
 	RequiresReplace []cty.Path
-	PlannedState    []byte
+	PlannedState    DynamicValue
 }
 
 func (s *Server) PlanResourceChange(ctx context.Context, req *PlanResourceChangeRequest) (*PlanResourceChangeResponse, error) {
 	r := s.Provider.ResourceFactory(req.TypeName)
-	blockType := blockType(r)
-	prior, err := msgpack.Unmarshal(req.PriorState, blockType)
+	schema := s.resourceSchema(req.TypeName, r)
+	blockType := schema.Type
+	prior, err := req.PriorState.Unmarshal(blockType)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	config, err := msgpack.Unmarshal(req.Config, blockType)
+	config, err := req.Config.Unmarshal(blockType)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	proposed, err := msgpack.Unmarshal(req.ProposedNewState, blockType)
+	proposed, err := req.ProposedNewState.Unmarshal(blockType)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -355,8 +451,9 @@ func (s *Server) PlanResourceChange(ctx context.Context, req *PlanResourceChange
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	planned = withTimeoutsBlock(r, planned, proposed)
 
-	schemaBlock := r.Schema().Block
+	schemaBlock := schema.Schema.Block
 	planned, err = cty.Transform(planned, func(path cty.Path, v cty.Value) (cty.Value, error) {
 		if len(path) == 0 {
 			// skip root
@@ -408,7 +505,7 @@ func (s *Server) PlanResourceChange(ctx context.Context, req *PlanResourceChange
 	if prior.IsNull() {
 		needsApply = true
 	} else {
-		potentialChanges, err := changes(r, prior, planned)
+		potentialChanges, err := changes(schema.Schema.Block, prior, planned)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -429,34 +526,35 @@ func (s *Server) PlanResourceChange(ctx context.Context, req *PlanResourceChange
 		}, nil
 	}
 
-	data, err := msgpack.Marshal(planned, blockType)
+	data, err := s.encode(planned, blockType)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to marshal state for resource: %s", req.TypeName)
 	}
 
 	var requiresReplace []cty.Path
 	if !prior.IsNull() {
-		potentialChanges, err := changes(r, prior, planned)
+		potentialChanges, err := changes(schema.Schema.Block, prior, planned)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
 
 		_, isUpdater := r.(Updater)
 
+		// multiple changes within a set share an addressable path
+		seen := cty.NewPathSet()
 		for _, c := range potentialChanges {
 			if !c.Attribute.IsArgument() {
 				//only check user supplied values
 				continue
 			}
 
-			if !isUpdater {
-				requiresReplace = append(requiresReplace, c.Path)
+			if seen.Has(c.AddressablePath) || isTimeoutsPath(c.Path) {
 				continue
 			}
 
-			if c.Attribute.ForceNew {
-				requiresReplace = append(requiresReplace, c.Path)
-				continue
+			if !isUpdater || c.Attribute.ForceNew {
+				seen.Add(c.AddressablePath)
+				requiresReplace = append(requiresReplace, c.AddressablePath)
 			}
 		}
 	}
@@ -470,39 +568,79 @@ func (s *Server) PlanResourceChange(ctx context.Context, req *PlanResourceChange
 
 type ApplyResourceChangeRequest struct {
 	TypeName     string
-	PlannedState []byte
-	PriorState   []byte
+	PlannedState DynamicValue
+	PriorState   DynamicValue
 }
 
 type ApplyResourceChangeResponse struct {
 	Diagnostics Diagnostics
-	NewState    []byte
+	NewState    DynamicValue
 }
 
 func (s *Server) ApplyResourceChange(ctx context.Context, req *ApplyResourceChangeRequest) (*ApplyResourceChangeResponse, error) {
+	ctx, done := s.inflight.track(ctx)
+	defer done()
+
+	if diags := s.configure.wait(ctx, "ApplyResourceChange"); diags.IsError() {
+		return &ApplyResourceChangeResponse{
+			Diagnostics: diags,
+		}, nil
+	}
+
+	release, err := s.acquireResource(ctx, req.TypeName)
+	if err != nil {
+		return &ApplyResourceChangeResponse{
+			Diagnostics: errorDiagnostics(errors.Wrapf(err, "cancelled waiting to run ApplyResourceChange for %s", req.TypeName)),
+		}, nil
+	}
+	defer release()
+
 	r := s.Provider.ResourceFactory(req.TypeName)
-	blockType := blockType(r)
-	planned, err := msgpack.Unmarshal(req.PlannedState, blockType)
+	schema := s.resourceSchema(req.TypeName, r)
+	blockType := schema.Type
+	_, span := startSpan(ctx, "unmarshal")
+	planned, err := req.PlannedState.Unmarshal(blockType)
 	if err != nil {
+		span.End()
 		return nil, errors.WithStack(err)
 	}
-	prior, err := msgpack.Unmarshal(req.PriorState, blockType)
+	prior, err := req.PriorState.Unmarshal(blockType)
 	if err != nil {
+		span.End()
 		return nil, errors.WithStack(err)
 	}
-
 	if planned.IsNull() {
 		// this is a delete, so can skip validation, and need to apply prior state
 		err = unmarshalState(r, prior)
+	} else {
+		err = unmarshalState(r, planned)
+	}
+	span.End()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	redactor := newRedactor(schema.Schema.Block, planned, prior)
+	if logger := Logger(ctx); logger.IsTrace() {
+		logger.Trace("applying resource change",
+			"prior", RedactValue(schema.Schema, prior).GoString(),
+			"planned", RedactValue(schema.Schema, planned).GoString())
+	}
+
+	if planned.IsNull() {
+		opCtx, cancel, timeout, err := operationContext(ctx, r, prior, operationDelete)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
+		defer cancel()
 
-		err = r.Delete(ctx)
-		diags, err := errorOrDiagnostics(err)
-		if err != nil {
-			return nil, errors.WithStack(err)
+		err = traced(opCtx, "delete", r.Delete)
+		if isDoesNotExistError(err) {
+			// already deleted outside of Terraform
+			Logger(ctx).Debug("resource to delete does not exist", "type", req.TypeName)
+			err = nil
 		}
+		err = timeoutError(opCtx, operationDelete, timeout, err)
+		diags := redactor.errorDiagnostics(err)
 		if diags.IsError() {
 			return &ApplyResourceChangeResponse{
 				Diagnostics: diags,
@@ -515,19 +653,10 @@ func (s *Server) ApplyResourceChange(ctx context.Context, req *ApplyResourceChan
 		}, nil
 	}
 
-	err = unmarshalState(r, planned)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
 	var diags Diagnostics
 	// re-validate again now that we have more info, only for create/update
 	if v, ok := r.(Validator); ok && !planned.IsNull() {
-		err := v.Validate()
-		diags, err = errorOrDiagnostics(err)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
+		diags = redactor.errorDiagnostics(validate(ctx, v))
 		if diags.IsError() {
 			return &ApplyResourceChangeResponse{
 				Diagnostics: diags,
@@ -542,37 +671,76 @@ func (s *Server) ApplyResourceChange(ctx context.Context, req *ApplyResourceChan
 	// 	}, nil
 	// }
 
+	op := operationUpdate
+	if prior.IsNull() {
+		op = operationCreate
+	}
+	opCtx, cancel, timeout, err := operationContext(ctx, r, planned, op)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer cancel()
+
 	err = nil
 	switch {
 	case prior.IsNull():
-		err = r.Create(ctx)
+		err = traced(opCtx, "create", r.Create)
 	case planned.IsNull():
 		// should not get here
 		panic("unexpected null planned state")
 	default:
+		var potentialChanges []change
+		potentialChanges, err = changes(schema.Schema.Block, prior, planned)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		onlyTimeouts := true
+		for _, c := range potentialChanges {
+			if c.Attribute.IsArgument() && !isTimeoutsPath(c.Path) {
+				onlyTimeouts = false
+				break
+			}
+		}
+		if onlyTimeouts {
+			// nothing to update remotely, just persist the new timeouts
+			break
+		}
+
 		updater, ok := r.(Updater)
 		if !ok {
 			return nil, errors.Errorf("attempting to update something with no Update implementation")
 		}
-		err = updater.Update(ctx)
-	}
-	appendDiags, err := errorOrDiagnostics(err)
-	if err != nil {
-		return nil, errors.WithStack(err)
+		err = traced(opCtx, "update", updater.Update)
+		if isDoesNotExistError(err) {
+			// deleted outside of Terraform since it was last read
+			data, err := s.encode(cty.NullVal(schema.Type), schema.Type)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to marshal state for resource: %s", req.TypeName)
+			}
+			// an error, as Terraform rejects a null state without one, and
+			// drops the resource from state
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Summary:  "Resource no longer exists",
+				Detail: fmt.Sprintf("The %s could not be updated because it was deleted outside of Terraform. "+
+					"It has been removed from the state and will be created again by the next apply.", req.TypeName),
+			})
+			return &ApplyResourceChangeResponse{
+				Diagnostics: diags,
+				NewState:    data,
+			}, nil
+		}
 	}
-	diags = append(diags, appendDiags...)
-	if diags.IsError() {
+	err = timeoutError(opCtx, op, timeout, err)
+	_, partial := asPartialStateError(err)
+	diags = append(diags, redactor.errorDiagnostics(err)...)
+	if diags.IsError() && !partial {
 		return &ApplyResourceChangeResponse{
 			Diagnostics: diags,
 		}, nil
 	}
 
-	state, err := r.MarshalState()
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
-	data, err := msgpack.Marshal(state, blockType)
+	data, err := s.encodeState(ctx, r, schema.Type, planned)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to marshal state for resource: %s", req.TypeName)
 	}
@@ -584,55 +752,131 @@ func (s *Server) ApplyResourceChange(ctx context.Context, req *ApplyResourceChan
 }
 
 type ImportResourceStateRequest struct {
+	TypeName string
+	ID       string
+}
+
+type ImportedResourceState struct {
+	TypeName string
+	State    DynamicValue
 }
 
 type ImportResourceStateResponse struct {
+	Diagnostics       Diagnostics
+	ImportedResources []ImportedResourceState
 }
 
-func (s *Server) ImportResourceState(context.Context, *ImportResourceStateRequest) (*ImportResourceStateResponse, error) {
-	panic("ImportResourceState not implemented")
+func (s *Server) ImportResourceState(ctx context.Context, req *ImportResourceStateRequest) (*ImportResourceStateResponse, error) {
+	ctx, done := s.inflight.track(ctx)
+	defer done()
+
+	if diags := s.configure.wait(ctx, "ImportResourceState"); diags.IsError() {
+		return &ImportResourceStateResponse{
+			Diagnostics: diags,
+		}, nil
+	}
+
+	release, err := s.acquireResource(ctx, req.TypeName)
+	if err != nil {
+		return &ImportResourceStateResponse{
+			Diagnostics: errorDiagnostics(errors.Wrapf(err, "cancelled waiting to run ImportResourceState for %s", req.TypeName)),
+		}, nil
+	}
+	defer release()
+
+	r := s.Provider.ResourceFactory(req.TypeName)
+	schema := s.resourceSchema(req.TypeName, r)
+	importer, ok := r.(Importer)
+	if !ok {
+		return &ImportResourceStateResponse{
+			Diagnostics: Diagnostics{
+				Diagnostic{
+					Severity: SeverityError,
+					Summary:  "Resource import not supported",
+					Detail:   fmt.Sprintf("The %s resource does not support import.", req.TypeName),
+				},
+			},
+		}, nil
+	}
+
+	err = traced(ctx, "import", func(ctx context.Context) error {
+		return importer.Import(ctx, req.ID)
+	})
+	if isDoesNotExistError(err) {
+		return &ImportResourceStateResponse{
+			Diagnostics: Diagnostics{
+				Diagnostic{
+					Severity: SeverityError,
+					Summary:  "Cannot import non-existent remote object",
+					Detail:   fmt.Sprintf("The %s with ID %q does not exist.", req.TypeName, req.ID),
+				},
+			},
+		}, nil
+	}
+	diags := newRedactor(schema.Schema.Block).errorDiagnostics(err)
+	if diags.IsError() {
+		return &ImportResourceStateResponse{
+			Diagnostics: diags,
+		}, nil
+	}
+
+	data, err := s.encodeState(ctx, r, schema.Type, cty.NullVal(schema.Type))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to marshal state for resource: %s", req.TypeName)
+	}
+
+	return &ImportResourceStateResponse{
+		Diagnostics: diags,
+		ImportedResources: []ImportedResourceState{
+			{TypeName: req.TypeName, State: data},
+		},
+	}, nil
 }
 
 type ReadDataSourceRequest struct {
 	TypeName string
-	Config   []byte
+	Config   DynamicValue
 }
 
 type ReadDataSourceResponse struct {
 	Diagnostics Diagnostics
-	State       []byte
+	State       DynamicValue
 }
 
 func (s *Server) ReadDataSource(ctx context.Context, req *ReadDataSourceRequest) (*ReadDataSourceResponse, error) {
-	ds := s.Provider.DataSourceFactory(req.TypeName)
-	blockType := blockType(ds)
+	ctx, done := s.inflight.track(ctx)
+	defer done()
 
-	config, err := msgpack.Unmarshal(req.Config, blockType)
-	if err != nil {
-		return nil, errors.WithStack(err)
+	if diags := s.configure.wait(ctx, "ReadDataSource"); diags.IsError() {
+		return &ReadDataSourceResponse{
+			Diagnostics: diags,
+		}, nil
 	}
-	err = unmarshalState(ds, config)
+
+	ds := s.Provider.DataSourceFactory(req.TypeName)
+	schema := s.dataSourceSchema(req.TypeName, ds)
+	config, err := decodeState(ctx, ds, schema.Type, req.Config)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	err = ds.Read(ctx)
-	diags, err := errorOrDiagnostics(err)
+	opCtx, cancel, timeout, err := operationContext(ctx, ds, config, operationRead)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	defer cancel()
+
+	err = traced(opCtx, "read", ds.Read)
+	err = timeoutError(opCtx, operationRead, timeout, err)
+	redactor := newRedactor(schema.Schema.Block, config)
+	diags := redactor.errorDiagnostics(err)
 	if diags.IsError() {
 		return &ReadDataSourceResponse{
 			Diagnostics: diags,
 		}, nil
 	}
 
-	state, err := ds.MarshalState()
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
-	data, err := msgpack.Marshal(state, blockType)
+	data, err := s.encodeState(ctx, ds, schema.Type, config)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to marshal state for data source: %s", req.TypeName)
 	}
@@ -643,6 +887,13 @@ func (s *Server) ReadDataSource(ctx context.Context, req *ReadDataSourceRequest)
 	}, nil
 }
 
+// Stop cancels the contexts of all in-flight operations and waits up to
+// StopTimeout for them to return before stopping the provider.
 func (s *Server) Stop(ctx context.Context) error {
-	return s.Provider.Stop(ctx)
+	s.inflight.cancelAndWait(ctx, s.StopTimeout)
+
+	if stopper, ok := s.Provider.(Stopper); ok {
+		return stopper.Stop(ctx)
+	}
+	return nil
 }
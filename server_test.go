@@ -0,0 +1,375 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+)
+
+func testMsgpack(t *testing.T, v cty.Value) DynamicValue {
+	t.Helper()
+
+	data, err := msgpack.Marshal(v, v.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return DynamicValue{MsgPack: data}
+}
+
+func testThingVal(id, name string, tags map[string]cty.Value) cty.Value {
+	tagsVal := cty.NullVal(cty.Map(cty.String))
+	if tags != nil {
+		tagsVal = cty.MapVal(tags)
+	}
+	return cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.StringVal(id),
+		"name": cty.StringVal(name),
+		"tags": tagsVal,
+	})
+}
+
+// testConfigure configures the test provider served by s.
+func testConfigure(tb testing.TB, s *Server) {
+	tb.Helper()
+
+	config := cty.ObjectVal(map[string]cty.Value{"endpoint": cty.NullVal(cty.String)})
+	data, err := NewDynamicValue(config, config.Type(), false)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	resp, err := s.Configure(context.Background(), &ConfigureRequest{Config: data})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if resp.Diagnostics.IsError() {
+		tb.Fatalf("unexpected diagnostics %v", resp.Diagnostics)
+	}
+}
+
+func TestServerPlanResourceChangeRequiresReplaceNested(t *testing.T) {
+	s := &Server{Provider: newTestProvider()}
+
+	prior := testThingVal("test-id", "a", map[string]cty.Value{"k1": cty.StringVal("v1")})
+	proposed := testThingVal("test-id", "a", map[string]cty.Value{"k1": cty.StringVal("v1"), "k2": cty.StringVal("v2")})
+
+	resp, err := s.PlanResourceChange(context.Background(), &PlanResourceChangeRequest{
+		TypeName:         "test_thing",
+		PriorState:       testMsgpack(t, prior),
+		Config:           testMsgpack(t, proposed),
+		ProposedNewState: testMsgpack(t, proposed),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.RequiresReplace) != 1 {
+		t.Fatalf("expected 1 requires replace path, got %#v", resp.RequiresReplace)
+	}
+	expected := cty.GetAttrPath("tags").Index(cty.StringVal("k2"))
+	if !cty.NewPathSet(expected).Has(resp.RequiresReplace[0]) {
+		t.Fatalf("expected path %#v, got %#v", expected, resp.RequiresReplace[0])
+	}
+}
+
+func TestServerApplyResourceChangePartialState(t *testing.T) {
+	p := newTestProvider()
+	p.resources["test_thing"] = func() Resource {
+		return &testResource{
+			create: func(ctx context.Context, r *testResource) error {
+				r.ID = "half-created"
+				return errors.Wrap(PartialStateError(errors.New("timed out waiting for thing")), "create failed")
+			},
+		}
+	}
+	s := &Server{Provider: p}
+	testConfigure(t, s)
+
+	prior := cty.NullVal(testThingVal("", "", nil).Type())
+	planned := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("a"),
+		"tags": cty.NullVal(cty.Map(cty.String)),
+	})
+
+	resp, err := s.ApplyResourceChange(context.Background(), &ApplyResourceChangeRequest{
+		TypeName:     "test_thing",
+		PriorState:   testMsgpack(t, prior),
+		PlannedState: testMsgpack(t, planned),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !resp.Diagnostics.IsError() {
+		t.Fatalf("expected error diagnostics, got %#v", resp.Diagnostics)
+	}
+	if resp.NewState.IsEmpty() {
+		t.Fatal("expected new state to be returned")
+	}
+	state, err := resp.NewState.Unmarshal(prior.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := resp.Diagnostics[0].Summary; summary != "create failed: timed out waiting for thing" {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+	if id := state.GetAttr("id").AsString(); id != "half-created" {
+		t.Fatalf("unexpected id %q", id)
+	}
+}
+
+func TestServerApplyResourceChangePartialStateUpdate(t *testing.T) {
+	p := newTestProvider()
+	p.resources["test_thing"] = func() Resource {
+		return &testUpdaterResource{
+			update: func(ctx context.Context, r *testResource) error {
+				r.Tags = map[string]string{"k": "half-updated"}
+				return errors.Wrap(PartialStateError(errors.New("timed out waiting for thing")), "update failed")
+			},
+		}
+	}
+	s := &Server{Provider: p}
+	testConfigure(t, s)
+
+	prior := testThingVal("test-id", "a", nil)
+	resp, err := s.ApplyResourceChange(context.Background(), &ApplyResourceChangeRequest{
+		TypeName:     "test_thing",
+		PriorState:   testMsgpack(t, prior),
+		PlannedState: testMsgpack(t, testThingVal("test-id", "a", map[string]cty.Value{"k": cty.StringVal("v")})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !resp.Diagnostics.IsError() {
+		t.Fatalf("expected error diagnostics, got %#v", resp.Diagnostics)
+	}
+	if summary := resp.Diagnostics[0].Summary; summary != "update failed: timed out waiting for thing" {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+	if resp.NewState.IsEmpty() {
+		t.Fatal("expected new state to be returned")
+	}
+	state, err := resp.NewState.Unmarshal(prior.Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag := state.GetAttr("tags").Index(cty.StringVal("k")).AsString(); tag != "half-updated" {
+		t.Fatalf("unexpected tag %q", tag)
+	}
+}
+
+func TestServerApplyResourceChangeUpdateError(t *testing.T) {
+	p := newTestProvider()
+	p.resources["test_thing"] = func() Resource {
+		return &testUpdaterResource{
+			update: func(context.Context, *testResource) error {
+				return errors.New("boom")
+			},
+		}
+	}
+	s := &Server{Provider: p}
+	testConfigure(t, s)
+
+	resp, err := s.ApplyResourceChange(context.Background(), &ApplyResourceChangeRequest{
+		TypeName:     "test_thing",
+		PriorState:   testMsgpack(t, testThingVal("test-id", "a", nil)),
+		PlannedState: testMsgpack(t, testThingVal("test-id", "a", map[string]cty.Value{"k": cty.StringVal("v")})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Diagnostics.IsError() || resp.Diagnostics[0].Summary != "boom" {
+		t.Fatalf("expected error diagnostics, got %v", resp.Diagnostics)
+	}
+	if !resp.NewState.IsEmpty() {
+		t.Fatal("expected no new state")
+	}
+}
+
+func TestServerImportResourceState(t *testing.T) {
+	s := &Server{Provider: newTestProvider()}
+	testConfigure(t, s)
+
+	resp, err := s.ImportResourceState(context.Background(), &ImportResourceStateRequest{
+		TypeName: "test_thing",
+		ID:       "imported-id",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics %v", resp.Diagnostics)
+	}
+	if len(resp.ImportedResources) != 1 || resp.ImportedResources[0].TypeName != "test_thing" {
+		t.Fatalf("expected one test_thing, got %#v", resp.ImportedResources)
+	}
+	state, err := resp.ImportedResources[0].State.Unmarshal(testThingVal("", "", nil).Type())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := state.GetAttr("id").AsString(); id != "imported-id" {
+		t.Fatalf("unexpected id %q", id)
+	}
+}
+
+func TestServerStopCancelsInflight(t *testing.T) {
+	started := make(chan struct{})
+	p := newTestProvider()
+	p.resources["test_thing"] = func() Resource {
+		return &testResource{
+			create: func(ctx context.Context, r *testResource) error {
+				close(started)
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}
+	}
+	s := &Server{Provider: p}
+	testConfigure(t, s)
+
+	planned := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("a"),
+		"tags": cty.NullVal(cty.Map(cty.String)),
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		resp, err := s.ApplyResourceChange(context.Background(), &ApplyResourceChangeRequest{
+			TypeName:     "test_thing",
+			PriorState:   testMsgpack(t, cty.NullVal(planned.Type())),
+			PlannedState: testMsgpack(t, planned),
+		})
+		if err == nil {
+			err = resp.Diagnostics
+		}
+		errCh <- err
+	}()
+
+	<-started
+	err := s.Stop(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errCh:
+		if err == nil || err.Error() != context.Canceled.Error() {
+			t.Fatalf("expected cancelled error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("operation was not cancelled")
+	}
+}
+
+// testUpdaterResource is a testResource updated in place with update.
+type testUpdaterResource struct {
+	testResource
+	update func(context.Context, *testResource) error
+}
+
+func (r *testUpdaterResource) Update(ctx context.Context) error {
+	return r.update(ctx, &r.testResource)
+}
+
+func TestServerDoesNotExist(t *testing.T) {
+	notFound := func(ctx context.Context, r *testResource) error {
+		return errors.Wrapf(DoesNotExistError(), "reading thing %s", r.ID)
+	}
+	p := newTestProvider()
+	p.resources["test_thing"] = func() Resource {
+		return &testUpdaterResource{
+			testResource: testResource{read: notFound, delete: notFound},
+			update:       notFound,
+		}
+	}
+	s := &Server{Provider: p}
+	testConfigure(t, s)
+	ctx := context.Background()
+
+	prior := testThingVal("test-id", "a", nil)
+	nullState := cty.NullVal(prior.Type())
+
+	read, err := s.ReadResource(ctx, &ReadResourceRequest{
+		TypeName:     "test_thing",
+		CurrentState: testMsgpack(t, prior),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Diagnostics) != 0 {
+		t.Fatalf("unexpected read diagnostics %v", read.Diagnostics)
+	}
+	if state, err := read.NewState.Unmarshal(prior.Type()); err != nil || !state.IsNull() {
+		t.Fatalf("expected read to return null state, got %#v, %v", state, err)
+	}
+
+	deleted, err := s.ApplyResourceChange(ctx, &ApplyResourceChangeRequest{
+		TypeName:     "test_thing",
+		PriorState:   testMsgpack(t, prior),
+		PlannedState: testMsgpack(t, nullState),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted.Diagnostics) != 0 {
+		t.Fatalf("expected delete to succeed, got %v", deleted.Diagnostics)
+	}
+
+	updated, err := s.ApplyResourceChange(ctx, &ApplyResourceChangeRequest{
+		TypeName:     "test_thing",
+		PriorState:   testMsgpack(t, prior),
+		PlannedState: testMsgpack(t, testThingVal("test-id", "a", map[string]cty.Value{"k": cty.StringVal("v")})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Diagnostics) != 1 || updated.Diagnostics[0].Severity != SeverityError || updated.Diagnostics[0].Summary != "Resource no longer exists" {
+		t.Fatalf("expected update to fail, got %v", updated.Diagnostics)
+	}
+	if state, err := updated.NewState.Unmarshal(prior.Type()); err != nil || !state.IsNull() {
+		t.Fatalf("expected update to return null state, got %#v, %v", state, err)
+	}
+}
+
+func TestServerDoesNotExistOtherErrors(t *testing.T) {
+	failed := func(ctx context.Context, r *testResource) error {
+		return errors.Wrapf(errors.New("permission denied"), "changing thing %s", r.ID)
+	}
+	p := newTestProvider()
+	p.resources["test_thing"] = func() Resource {
+		return &testUpdaterResource{
+			testResource: testResource{delete: failed},
+			update:       failed,
+		}
+	}
+	s := &Server{Provider: p}
+	testConfigure(t, s)
+	ctx := context.Background()
+
+	prior := testThingVal("test-id", "a", nil)
+	for _, planned := range []cty.Value{
+		cty.NullVal(prior.Type()),
+		testThingVal("test-id", "a", map[string]cty.Value{"k": cty.StringVal("v")}),
+	} {
+		resp, err := s.ApplyResourceChange(ctx, &ApplyResourceChangeRequest{
+			TypeName:     "test_thing",
+			PriorState:   testMsgpack(t, prior),
+			PlannedState: testMsgpack(t, planned),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.Diagnostics.IsError() || resp.Diagnostics[0].Summary != "changing thing test-id: permission denied" {
+			t.Fatalf("expected error diagnostics, got %v", resp.Diagnostics)
+		}
+		if !resp.NewState.IsEmpty() {
+			t.Fatal("expected no new state")
+		}
+	}
+}
@@ -1,14 +1,23 @@
 package sdk
 
 import (
+	"context"
+
 	"github.com/pkg/errors"
 	"github.com/zclconf/go-cty/cty"
 )
 
+// schemaOf returns the schema of target including any SDK managed blocks.
+func schemaOf(target interface {
+	Schema() Schema
+}) Schema {
+	return injectSchema(target, target.Schema())
+}
+
 func blockType(target interface {
 	Schema() Schema
 }) cty.Type {
-	return target.Schema().Block.impliedType()
+	return schemaOf(target).Block.ImpliedType()
 }
 
 func unmarshalState(target interface {
@@ -27,3 +36,22 @@ func unmarshalState(target interface {
 
 	return nil
 }
+
+// decodeState decodes v as a value of ty and unmarshals it into target,
+// within an unmarshal span if ctx is traced.
+func decodeState(ctx context.Context, target interface {
+	UnmarshalState(cty.Value) error
+}, ty cty.Type, v DynamicValue) (cty.Value, error) {
+	_, span := startSpan(ctx, "unmarshal")
+	defer span.End()
+
+	val, err := v.Unmarshal(ty)
+	if err != nil {
+		return cty.NilVal, errors.WithStack(err)
+	}
+	err = unmarshalState(target, val)
+	if err != nil {
+		return cty.NilVal, errors.WithStack(err)
+	}
+	return val, nil
+}
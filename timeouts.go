@@ -0,0 +1,195 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const timeoutsBlockName = "timeouts"
+
+const (
+	operationCreate = "create"
+	operationRead   = "read"
+	operationUpdate = "update"
+	operationDelete = "delete"
+)
+
+// Timeouts are the default durations of resource operations. A zero
+// duration means the operation has no deadline.
+type Timeouts struct {
+	Create time.Duration
+	Read   time.Duration
+	Update time.Duration
+	Delete time.Duration
+}
+
+func (t Timeouts) operation(op string) time.Duration {
+	switch op {
+	case operationCreate:
+		return t.Create
+	case operationRead:
+		return t.Read
+	case operationUpdate:
+		return t.Update
+	case operationDelete:
+		return t.Delete
+	}
+	return 0
+}
+
+// TimeoutDefaulter can be implemented by resources and data sources to
+// declare default operation timeouts. Implementing it adds a timeouts
+// block to the schema so users can override the defaults:
+//
+//	timeouts {
+//	  create = "30m"
+//	}
+//
+// The timeouts are applied as deadlines to the context passed to each
+// operation.
+type TimeoutDefaulter interface {
+	DefaultTimeouts() Timeouts
+}
+
+func timeoutsNestedBlock() NestedBlock {
+	var atts Attributes
+	for _, op := range []string{operationCreate, operationRead, operationUpdate, operationDelete} {
+		atts = append(atts, Attribute{
+			Name:        op,
+			Description: fmt.Sprintf("Timeout for the %s operation, for example `30m`.", op),
+			Type:        cty.String,
+			Optional:    true,
+		})
+	}
+	return NestedBlock{
+		TypeName: timeoutsBlockName,
+		Nesting:  NestingSingle,
+		Block: Block{
+			Attributes: atts,
+		},
+	}
+}
+
+// injectSchema adds any blocks managed by the SDK on behalf of target
+// to its schema.
+func injectSchema(target interface{}, s Schema) Schema {
+	if _, ok := target.(TimeoutDefaulter); ok {
+		blockTypes := make([]NestedBlock, 0, len(s.Block.BlockTypes)+1)
+		blockTypes = append(blockTypes, s.Block.BlockTypes...)
+		s.Block.BlockTypes = append(blockTypes, timeoutsNestedBlock())
+	}
+	return s
+}
+
+func isTimeoutsPath(path cty.Path) bool {
+	if len(path) == 0 {
+		return false
+	}
+	get, ok := path[0].(cty.GetAttrStep)
+	return ok && get.Name == timeoutsBlockName
+}
+
+// withTimeoutsBlock copies the timeouts block from src into state, as
+// targets do not track it in their own state.
+func withTimeoutsBlock(target interface{}, state, src cty.Value) cty.Value {
+	if _, ok := target.(TimeoutDefaulter); !ok {
+		return state
+	}
+	if state.IsNull() || !state.IsKnown() {
+		return state
+	}
+
+	timeouts := cty.NullVal(timeoutsNestedBlock().ImpliedType())
+	if !src.IsNull() && src.IsKnown() && src.Type().IsObjectType() && src.Type().HasAttribute(timeoutsBlockName) {
+		timeouts = src.GetAttr(timeoutsBlockName)
+	}
+
+	atts := map[string]cty.Value{}
+	for k, v := range state.AsValueMap() {
+		atts[k] = v
+	}
+	atts[timeoutsBlockName] = timeouts
+	return cty.ObjectVal(atts)
+}
+
+// operationTimeout returns the timeout of op for target, preferring the
+// value configured in the timeouts block of v over the default.
+func operationTimeout(target interface{}, v cty.Value, op string) (time.Duration, error) {
+	td, ok := target.(TimeoutDefaulter)
+	if !ok {
+		return 0, nil
+	}
+	timeout := td.DefaultTimeouts().operation(op)
+
+	if v.IsNull() || !v.IsKnown() || !v.Type().HasAttribute(timeoutsBlockName) {
+		return timeout, nil
+	}
+	block := v.GetAttr(timeoutsBlockName)
+	if block.IsNull() || !block.IsKnown() {
+		return timeout, nil
+	}
+	raw := block.GetAttr(op)
+	if raw.IsNull() || !raw.IsKnown() {
+		return timeout, nil
+	}
+
+	timeout, err := time.ParseDuration(raw.AsString())
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to parse %s timeout", op)
+	}
+	return timeout, nil
+}
+
+// validateTimeouts checks the durations in the timeouts block of v.
+func validateTimeouts(target interface{}, v cty.Value) Diagnostics {
+	var diags Diagnostics
+	for _, op := range []string{operationCreate, operationRead, operationUpdate, operationDelete} {
+		_, err := operationTimeout(target, v, op)
+		if err != nil {
+			diags = append(diags, AttributeError(err.Error(), cty.GetAttrPath(timeoutsBlockName).GetAttr(op))...)
+		}
+	}
+	return diags
+}
+
+// operationContext returns a context for running op on target, with a
+// deadline set if a timeout applies.
+func operationContext(ctx context.Context, target interface{}, v cty.Value, op string) (context.Context, context.CancelFunc, time.Duration, error) {
+	timeout, err := operationTimeout(target, v, op)
+	if err != nil {
+		return nil, nil, 0, errors.WithStack(err)
+	}
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, 0, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout, nil
+}
+
+// timeoutError replaces an error returned after the deadline of ctx was
+// exceeded with a diagnostic explaining the timeout.
+func timeoutError(ctx context.Context, op string, timeout time.Duration, err error) error {
+	if err == nil || timeout <= 0 || ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+
+	diags := Diagnostics{
+		Diagnostic{
+			Severity: SeverityError,
+			Summary:  fmt.Sprintf("Timeout while waiting for %s to complete after %s", op, timeout),
+			Detail: fmt.Sprintf("%s\n\nThe %s operation did not complete before its deadline. "+
+				"The timeout can be increased with a timeouts block in the resource configuration, "+
+				"for example:\n\n  timeouts {\n    %s = \"%s\"\n  }", err, op, op, 2*timeout),
+		},
+	}
+
+	if _, ok := asPartialStateError(err); ok {
+		return PartialStateError(diags)
+	}
+	return diags
+}
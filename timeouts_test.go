@@ -0,0 +1,127 @@
+package sdk
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+type testTimeoutsResource struct {
+	testResource
+}
+
+func (r *testTimeoutsResource) DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Create: time.Hour,
+	}
+}
+
+func newTestTimeoutsProvider(create func(context.Context, *testResource) error) *testProvider {
+	p := newTestProvider()
+	p.resources["test_thing"] = func() Resource {
+		return &testTimeoutsResource{
+			testResource: testResource{
+				create: create,
+			},
+		}
+	}
+	return p
+}
+
+func testThingTimeoutsVal(v cty.Value, create string) cty.Value {
+	atts := v.AsValueMap()
+	atts["timeouts"] = cty.ObjectVal(map[string]cty.Value{
+		"create": cty.StringVal(create),
+		"read":   cty.NullVal(cty.String),
+		"update": cty.NullVal(cty.String),
+		"delete": cty.NullVal(cty.String),
+	})
+	return cty.ObjectVal(atts)
+}
+
+func TestServerGetSchemaTimeouts(t *testing.T) {
+	s := &Server{Provider: newTestTimeoutsProvider(nil)}
+
+	resp, err := s.GetSchema(context.Background(), &GetSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := resp.ResourceSchemas["test_thing"].Block
+	if len(block.BlockTypes) != 1 || block.BlockTypes[0].TypeName != "timeouts" {
+		t.Fatalf("expected timeouts block, got %#v", block.BlockTypes)
+	}
+}
+
+func TestServerApplyResourceChangeTimeout(t *testing.T) {
+	var deadline time.Time
+	s := &Server{Provider: newTestTimeoutsProvider(func(ctx context.Context, r *testResource) error {
+		deadline, _ = ctx.Deadline()
+		<-ctx.Done()
+		return ctx.Err()
+	})}
+	testConfigure(t, s)
+
+	planned := testThingTimeoutsVal(cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("a"),
+		"tags": cty.NullVal(cty.Map(cty.String)),
+	}), "10ms")
+
+	start := time.Now()
+	resp, err := s.ApplyResourceChange(context.Background(), &ApplyResourceChangeRequest{
+		TypeName:     "test_thing",
+		PriorState:   testMsgpack(t, cty.NullVal(planned.Type())),
+		PlannedState: testMsgpack(t, planned),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deadline.Sub(start) > time.Second {
+		t.Fatalf("expected configured timeout to override default, deadline in %s", deadline.Sub(start))
+	}
+	if !resp.Diagnostics.IsError() || !strings.Contains(resp.Diagnostics[0].Summary, "Timeout while waiting for create") {
+		t.Fatalf("unexpected diagnostics %#v", resp.Diagnostics)
+	}
+}
+
+func TestServerPlanResourceChangeTimeoutsOnly(t *testing.T) {
+	s := &Server{Provider: newTestTimeoutsProvider(nil)}
+
+	prior := testThingTimeoutsVal(testThingVal("test-id", "a", nil), "10m")
+	proposed := testThingTimeoutsVal(testThingVal("test-id", "a", nil), "20m")
+
+	resp, err := s.PlanResourceChange(context.Background(), &PlanResourceChangeRequest{
+		TypeName:         "test_thing",
+		PriorState:       testMsgpack(t, prior),
+		Config:           testMsgpack(t, proposed),
+		ProposedNewState: testMsgpack(t, proposed),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.RequiresReplace) != 0 {
+		t.Fatalf("expected no replacement, got %#v", resp.RequiresReplace)
+	}
+}
+
+func TestServerValidateResourceTypeConfigTimeouts(t *testing.T) {
+	s := &Server{Provider: newTestTimeoutsProvider(nil)}
+
+	config := testThingTimeoutsVal(testThingVal("", "a", nil), "soon")
+
+	resp, err := s.ValidateResourceTypeConfig(context.Background(), &ValidateResourceTypeConfigRequest{
+		TypeName: "test_thing",
+		Config:   testMsgpack(t, config),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Diagnostics.IsError() {
+		t.Fatal("expected invalid duration to be reported")
+	}
+}
@@ -0,0 +1,150 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+)
+
+// EnvTraceFile is the environment variable naming a file to which a span
+// is appended as a line of JSON for every RPC served, and for the steps of
+// the RPC.
+const EnvTraceFile = "TF_PLUGIN_TRACE_FILE"
+
+var enableTracingOnce sync.Once
+
+// enableTracing registers a file exporter if EnvTraceFile is set, reporting
+// whether tracing is enabled.
+func enableTracing() bool {
+	path := os.Getenv(EnvTraceFile)
+	if path == "" {
+		return false
+	}
+
+	enableTracingOnce.Do(func() {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			Logger(context.Background()).Error("unable to open trace file", "path", path, "error", err)
+			return
+		}
+		trace.RegisterExporter(newFileExporter(f))
+	})
+	return true
+}
+
+// TracingServerInterceptor starts a span for every unary RPC, which the
+// context carries into provider code. Spans are only exported by a
+// registered exporter, see EnvTraceFile.
+func TracingServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := trace.StartSpan(ctx, strings.TrimPrefix(info.FullMethod, "/"), trace.WithSampler(trace.AlwaysSample()))
+		defer span.End()
+		if tn, ok := req.(interface{ GetTypeName() string }); ok && tn.GetTypeName() != "" {
+			span.AddAttributes(trace.StringAttribute("type", tn.GetTypeName()))
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+		}
+		return resp, err
+	}
+}
+
+// TracingStreamServerInterceptor starts a span for every streaming RPC.
+func TracingStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := trace.StartSpan(ss.Context(), strings.TrimPrefix(info.FullMethod, "/"), trace.WithSampler(trace.AlwaysSample()))
+		defer span.End()
+
+		err := handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+		if err != nil {
+			span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+		}
+		return err
+	}
+}
+
+// startSpan starts a child span if ctx is traced, otherwise the returned
+// span is nil, which is safe to end.
+func startSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
+	if trace.FromContext(ctx) == nil {
+		return ctx, nil
+	}
+	return trace.StartSpan(ctx, name)
+}
+
+// traced calls f within a child span if ctx is traced.
+func traced(ctx context.Context, name string, f func(context.Context) error) error {
+	ctx, span := startSpan(ctx, name)
+	defer span.End()
+
+	err := f(ctx)
+	if err != nil {
+		// the message is left out as it may contain sensitive values
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown})
+	}
+	return err
+}
+
+// validate calls v within a validate span if ctx is traced.
+func validate(ctx context.Context, v Validator) error {
+	_, span := startSpan(ctx, "validate")
+	defer span.End()
+	return v.Validate()
+}
+
+// fileExporter writes spans as lines of JSON.
+type fileExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newFileExporter(w io.Writer) *fileExporter {
+	return &fileExporter{enc: json.NewEncoder(w)}
+}
+
+type jsonSpan struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	Start        time.Time              `json:"start"`
+	End          time.Time              `json:"end"`
+	DurationMS   float64                `json:"duration_ms"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	StatusCode   int32                  `json:"status_code,omitempty"`
+	Status       string                 `json:"status,omitempty"`
+}
+
+func (e *fileExporter) ExportSpan(sd *trace.SpanData) {
+	span := jsonSpan{
+		TraceID:    sd.TraceID.String(),
+		SpanID:     sd.SpanID.String(),
+		Name:       sd.Name,
+		Start:      sd.StartTime,
+		End:        sd.EndTime,
+		DurationMS: float64(sd.EndTime.Sub(sd.StartTime)) / float64(time.Millisecond),
+		Attributes: sd.Attributes,
+		StatusCode: sd.Code,
+		Status:     sd.Message,
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		span.ParentSpanID = sd.ParentSpanID.String()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	err := e.enc.Encode(span)
+	if err != nil {
+		Logger(context.Background()).Error("unable to export span", "error", errors.WithStack(err))
+	}
+}
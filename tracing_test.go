@@ -0,0 +1,99 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+
+	pb "github.com/hashicorp/terraform-plugin-sdk/tfplugin5"
+)
+
+type testExporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (e *testExporter) ExportSpan(sd *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, sd)
+}
+
+func TestTracingServerInterceptor(t *testing.T) {
+	exporter := &testExporter{}
+	trace.RegisterExporter(exporter)
+	defer trace.UnregisterExporter(exporter)
+
+	s := &Server{Provider: newTestProvider()}
+	testConfigure(t, s)
+	current := testThingVal("test-id", "a", nil)
+
+	interceptor := TracingServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/tfplugin5.Provider/ReadResource"}
+	_, err := interceptor(context.Background(), &pb.ReadResource_Request{TypeName: "test_thing"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.ReadResource(ctx, &ReadResourceRequest{
+			TypeName:     "test_thing",
+			CurrentState: testMsgpack(t, current),
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var root *trace.SpanData
+	var children []string
+	for _, sd := range exporter.spans {
+		if sd.ParentSpanID == (trace.SpanID{}) {
+			root = sd
+			continue
+		}
+		children = append(children, sd.Name)
+	}
+	if root == nil || root.Name != "tfplugin5.Provider/ReadResource" {
+		t.Fatalf("expected root span for the RPC, got %v", root)
+	}
+	if root.Attributes["type"] != "test_thing" {
+		t.Fatalf("expected type attribute, got %v", root.Attributes)
+	}
+	sort.Strings(children)
+	expected := []string{"marshal", "read", "unmarshal"}
+	if fmt.Sprint(children) != fmt.Sprint(expected) {
+		t.Fatalf("expected child spans %v, got %v", expected, children)
+	}
+	for _, sd := range exporter.spans {
+		if sd.TraceID != root.TraceID {
+			t.Fatalf("span %s is not part of the RPC trace", sd.Name)
+		}
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter := newFileExporter(&buf)
+
+	_, span := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+	span.End()
+
+	exporter.ExportSpan(&trace.SpanData{
+		SpanContext:  trace.SpanContext{TraceID: span.SpanContext().TraceID, SpanID: trace.SpanID{1}},
+		ParentSpanID: span.SpanContext().SpanID,
+		Name:         "child",
+		Attributes:   map[string]interface{}{"type": "test_thing"},
+	})
+
+	var actual jsonSpan
+	err := json.Unmarshal(buf.Bytes(), &actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.Name != "child" || actual.ParentSpanID != span.SpanContext().SpanID.String() || actual.Attributes["type"] != "test_thing" {
+		t.Fatalf("unexpected span %#v", actual)
+	}
+}